@@ -0,0 +1,211 @@
+package pgxorb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+)
+
+// BoundOf returns the bounding box of geom, to be sent alongside it into a
+// box2d or geometry column instead of computing it with ST_Envelope on the
+// server. A nil geom has an empty bound at the origin.
+func BoundOf(geom orb.Geometry) orb.Bound {
+	if geom == nil {
+		return orb.Bound{}
+	}
+
+	return geom.Bound()
+}
+
+// BoundOfAntimeridian is BoundOf returning the narrowest bound of geometries
+// in lon/lat coordinates crossing the antimeridian, such as a line from 170
+// to -170 degrees of longitude, instead of one spanning the world. Such a
+// bound has a Min longitude greater than its Max, as a GeoJSON bbox crossing
+// the antimeridian, and must be split in two before being sent as a box2d or
+// geometry.
+func BoundOfAntimeridian(geom orb.Geometry) orb.Bound {
+	if geom == nil {
+		return orb.Bound{}
+	}
+
+	return wrapBound(geom, geom.Bound())
+}
+
+// wrapBound returns the narrowest longitude range holding the coordinates of
+// geom, allowing it to cross the antimeridian, with the latitudes of bound.
+// The range wraps when the widest gap between consecutive longitudes lies
+// within bound rather than across the antimeridian.
+func wrapBound(geom orb.Geometry, bound orb.Bound) orb.Bound {
+	// The longitudes outside bound span at least 180 degrees, more than any
+	// gap within it.
+	if bound.Max[0]-bound.Min[0] <= 180 {
+		return bound
+	}
+
+	var lons []float64
+	eachPoint(geom, func(p orb.Point) bool {
+		lons = append(lons, p[0])
+		return true
+	})
+	slices.Sort(lons)
+
+	gap, east := 360-(bound.Max[0]-bound.Min[0]), -1
+	for i := 1; i < len(lons); i++ {
+		if d := lons[i] - lons[i-1]; d > gap {
+			gap, east = d, i-1
+		}
+	}
+
+	if east >= 0 {
+		bound.Min[0], bound.Max[0] = lons[east+1], lons[east]
+	}
+
+	return bound
+}
+
+// box2dCodec implements [github.com/jackc/pgx/v5/pgtype.Codec] for the
+// PostGIS box2d type as an [orb.Bound]. box2d has no binary I/O, so only the
+// text format BOX(xmin ymin,xmax ymax) is supported.
+type box2dCodec struct{}
+
+// A box2dEncodePlan implements [github.com/jackc/pgx/v5/pgtype.EncodePlan]
+// for [orb.Bound] in text format.
+type box2dEncodePlan struct{}
+
+// A box2dScanPlan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan] for
+// *[orb.Bound] in text format.
+type box2dScanPlan struct{}
+
+// FormatSupported implements
+// [github.com/jackc/pgx/v5/pgtype.Codec.FormatSupported].
+func (box2dCodec) FormatSupported(format int16) bool {
+	return format == pgtype.TextFormatCode
+}
+
+// PreferredFormat implements
+// [github.com/jackc/pgx/v5/pgtype.Codec.PreferredFormat].
+func (box2dCodec) PreferredFormat() int16 {
+	return pgtype.TextFormatCode
+}
+
+// PlanEncode implements [github.com/jackc/pgx/v5/pgtype.Codec.PlanEncode].
+func (box2dCodec) PlanEncode(m *pgtype.Map, oid uint32, format int16, value any) pgtype.EncodePlan {
+	if format != pgtype.TextFormatCode {
+		return nil
+	}
+
+	switch value.(type) {
+	case orb.Bound, *orb.Bound:
+		return box2dEncodePlan{}
+	default:
+		return nil
+	}
+}
+
+// PlanScan implements [github.com/jackc/pgx/v5/pgtype.Codec.PlanScan].
+func (box2dCodec) PlanScan(m *pgtype.Map, oid uint32, format int16, target any) pgtype.ScanPlan {
+	if format != pgtype.TextFormatCode {
+		return nil
+	}
+
+	if _, ok := target.(*orb.Bound); !ok {
+		return nil
+	}
+
+	return box2dScanPlan{}
+}
+
+// DecodeDatabaseSQLValue implements
+// [github.com/jackc/pgx/v5/pgtype.Codec.DecodeDatabaseSQLValue].
+func (box2dCodec) DecodeDatabaseSQLValue(m *pgtype.Map, oid uint32, format int16, src []byte) (driver.Value, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	return string(src), nil
+}
+
+// DecodeValue implements [github.com/jackc/pgx/v5/pgtype.Codec.DecodeValue].
+func (box2dCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	var b orb.Bound
+	if err := (box2dScanPlan{}).Scan(src, &b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Encode implements [github.com/jackc/pgx/v5/pgtype.EncodePlan.Encode].
+func (box2dEncodePlan) Encode(value any, buf []byte) (newBuf []byte, err error) {
+	var b orb.Bound
+	switch v := value.(type) {
+	case orb.Bound:
+		b = v
+	case *orb.Bound:
+		if v == nil {
+			return nil, nil
+		}
+		b = *v
+	default:
+		return nil, errors.ErrUnsupported
+	}
+
+	buf = append(buf, "BOX("...)
+	buf = strconv.AppendFloat(buf, b.Min[0], 'g', -1, 64)
+	buf = append(buf, ' ')
+	buf = strconv.AppendFloat(buf, b.Min[1], 'g', -1, 64)
+	buf = append(buf, ',')
+	buf = strconv.AppendFloat(buf, b.Max[0], 'g', -1, 64)
+	buf = append(buf, ' ')
+	buf = strconv.AppendFloat(buf, b.Max[1], 'g', -1, 64)
+
+	return append(buf, ')'), nil
+}
+
+// Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
+func (box2dScanPlan) Scan(src []byte, target any) error {
+	dst, ok := target.(*orb.Bound)
+	if !ok || dst == nil {
+		return fmt.Errorf("target must be a non-nil *orb.Bound, got %T", target)
+	}
+
+	if src == nil {
+		return nil
+	}
+
+	var b orb.Bound
+	_, err := fmt.Sscanf(string(src), "BOX(%g %g,%g %g)", &b.Min[0], &b.Min[1], &b.Max[0], &b.Max[1])
+	if err != nil {
+		return fmt.Errorf("invalid box2d %q: %w", src, err)
+	}
+	*dst = b
+
+	return nil
+}
+
+func registerBox2D(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	box2dOID, err := typeOID(ctx, conn, cfg, "box2d")
+	if err != nil {
+		return err
+	}
+
+	conn.TypeMap().RegisterType(&pgtype.Type{
+		Name:  "box2d",
+		Codec: box2dCodec{},
+		OID:   box2dOID,
+	})
+	cfg.debug(ctx, "registered codec", "type", "box2d", "oid", box2dOID)
+
+	return nil
+}
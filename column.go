@@ -0,0 +1,46 @@
+package pgxorb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CheckColumnSRID reports an error when srid doesn't satisfy the SRID
+// constraint of the geometry or geography column table.column, e.g. when
+// passing an SRID 4326 geometry to a geometry(Point, 3857) column. Columns
+// without an SRID constraint accept any srid. table may be schema qualified.
+func CheckColumnSRID(ctx context.Context, conn *pgx.Conn, table, column string, srid int) error {
+	var typmod int32
+	err := conn.QueryRow(ctx,
+		`select a.atttypmod from pg_attribute a
+		 where a.attrelid = $1::text::regclass and a.attname = $2 and not a.attisdropped`,
+		table, column,
+	).Scan(&typmod)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("column %s.%s not found", table, column)
+	}
+	if err != nil {
+		return fmt.Errorf("get typmod of %s.%s failed: %w", table, column, err)
+	}
+
+	columnSRID := typmodSRID(typmod)
+	if columnSRID != 0 && columnSRID != srid {
+		return fmt.Errorf("srid %d doesn't match srid %d of column %s.%s", srid, columnSRID, table, column)
+	}
+
+	return nil
+}
+
+// typmodSRID extracts the SRID from a PostGIS type modifier, which packs it
+// as a signed 21-bit value above the geometry type and dimension bits. An
+// unconstrained column (typmod -1) has SRID 0.
+func typmodSRID(typmod int32) int {
+	if typmod < 0 {
+		return 0
+	}
+
+	return int(((typmod & 0x0FFFFF00) - (typmod & 0x08000000)) >> 8)
+}
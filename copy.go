@@ -0,0 +1,69 @@
+package pgxorb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/paulmach/orb"
+)
+
+// A RowWithGeometry holds the values of one row for [CopyFromGeometries] in
+// the order of its columns, with geometries given as orb values.
+type RowWithGeometry []any
+
+// CopyFromGeometries bulk loads the rows received from rows into the given
+// columns of table using the COPY protocol, until rows is closed. Rows are
+// encoded as they arrive, so producers and the copy run concurrently, and
+// geometry columns go through the binary codec registered on conn. It
+// returns the number of rows copied; cancelling ctx aborts the copy.
+func CopyFromGeometries(
+	ctx context.Context,
+	conn *pgx.Conn,
+	table pgx.Identifier,
+	columns []string,
+	rows <-chan RowWithGeometry,
+) (int64, error) {
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		select {
+		case row, ok := <-rows:
+			if !ok {
+				return nil, nil
+			}
+			if row == nil {
+				return nil, errors.New("copy row must not be nil")
+			}
+			return row, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+
+	return conn.CopyFrom(ctx, table, columns, src)
+}
+
+// DecodeCopyField decodes a single geometry field of a
+// COPY ... TO STDOUT (FORMAT binary) stream. src must start at the field's
+// 32-bit length prefix and hold exactly one field; a length of -1 denotes
+// NULL and yields a nil geometry.
+func DecodeCopyField(src []byte) (orb.Geometry, error) {
+	if len(src) < 4 {
+		return nil, fmt.Errorf("copy field too short: %d bytes", len(src))
+	}
+
+	size := int32(binary.BigEndian.Uint32(src))
+	src = src[4:]
+
+	if size == -1 {
+		return nil, nil
+	}
+
+	if size < 0 || int(size) != len(src) {
+		return nil, fmt.Errorf("copy field length %d doesn't match %d bytes of data", size, len(src))
+	}
+
+	geom, _, err := unmarshalGeometry(src)
+	return geom, err
+}
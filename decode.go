@@ -0,0 +1,296 @@
+package pgxorb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// DecodeReader decodes a single EWKB geometry from r, which may deliver the
+// bytes in arbitrary chunks. The geometry's header and element counts
+// determine how many bytes to read, so r is consumed exactly up to the end
+// of the geometry and successive calls decode successive geometries. It
+// returns io.EOF when r is empty and io.ErrUnexpectedEOF when r ends in the
+// middle of a geometry.
+func DecodeReader(r io.Reader) (orb.Geometry, error) {
+	var raw bytes.Buffer
+
+	w := ewkbWalker{r: r, raw: &raw}
+	if err := w.geometry(); err != nil {
+		return nil, err
+	}
+
+	geom, _, err := unmarshalGeometry(raw.Bytes())
+	return geom, err
+}
+
+// DecodeBytea decodes the EWKB geometry stored in a bytea column, as found
+// in schemas predating PostGIS columns. src may be the raw bytes scanned into
+// a []byte or the hex text format of bytea, starting with \x. An empty src,
+// such as a NULL scanned into a []byte, decodes to a nil geometry.
+func DecodeBytea(src []byte) (orb.Geometry, error) {
+	if hexSrc, ok := bytes.CutPrefix(src, []byte(`\x`)); ok {
+		var err error
+		src, err = decodeHex(hexSrc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea hex format: %w", err)
+		}
+	}
+
+	if len(src) == 0 {
+		return nil, nil
+	}
+
+	geom, _, err := unmarshalGeometry(src)
+	return geom, err
+}
+
+// DecodeReplicationField decodes a geometry or geography column value of a
+// logical replication tuple, such as the Data of a pgoutput TupleDataColumn,
+// sent in format: text ('t' columns) holds the hex EWKB of the text output,
+// and binary ('b' columns, with the binary option of pgoutput) the EWKB of
+// geometry_send. A nil src, as for NULL ('n') and unchanged TOAST ('u')
+// columns, decodes to a nil geometry.
+func DecodeReplicationField(format int16, src []byte) (orb.Geometry, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	switch format {
+	case pgtype.BinaryFormatCode:
+	case pgtype.TextFormatCode:
+		var err error
+		src, err = decodeHex(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replication field hex format: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown replication field format %d", format)
+	}
+
+	geom, _, err := unmarshalGeometry(src)
+	return geom, err
+}
+
+// DecodeWithMetrics decodes the EWKB geometry in src along with its planar
+// area and length, computed on the client in the units of its coordinates
+// instead of with ST_Area and ST_Length on the server. As with
+// [planar.Length], the length of a polygon is its perimeter, and points have
+// neither area nor length.
+func DecodeWithMetrics(src []byte) (geom orb.Geometry, area, length float64, err error) {
+	geom, _, err = unmarshalGeometry(src)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return geom, planar.Area(geom), planar.Length(geom), nil
+}
+
+// DecodeCoords streams the coordinates of the EWKB geometry in src to fn in
+// order, along with their index in the geometry, without materializing it.
+// Rings and members are walked in turn, so the index runs on across them. Z
+// and M ordinates are dropped. Decoding stops at the first error returned by
+// fn, which DecodeCoords returns.
+func DecodeCoords(src []byte, fn func(i int, c orb.Point) error) error {
+	i := 0
+	w := ewkbWalker{
+		r: bytes.NewReader(src),
+		coord: func(p orb.Point) error {
+			err := fn(i, p)
+			i++
+			return err
+		},
+	}
+
+	if err := w.geometry(); err != nil {
+		return err
+	}
+
+	if w.consumed != int64(len(src)) {
+		return fmt.Errorf("%d trailing bytes after ewkb geometry", int64(len(src))-w.consumed)
+	}
+
+	return nil
+}
+
+// VertexCount returns the number of coordinates of the EWKB geometry in src,
+// as ST_NPoints does, e.g. for estimating the cost of processing it. Only the
+// structure of the geometry is read; the coordinates of lines and rings are
+// skipped and nothing is allocated for them. An empty point, whose EWKB
+// holds NaN coordinates, has none.
+func VertexCount(src []byte) (int, error) {
+	w := ewkbWalker{r: bytes.NewReader(src)}
+	if err := w.geometry(); err != nil {
+		return 0, err
+	}
+
+	if w.consumed != int64(len(src)) {
+		return 0, fmt.Errorf("%d trailing bytes after ewkb geometry", int64(len(src))-w.consumed)
+	}
+
+	return int(w.vertices), nil
+}
+
+// errStopWalk stops a walk once its coordinate callback has seen enough.
+var errStopWalk = errors.New("stop walk")
+
+// PreviewPoint returns the first coordinate of the EWKB geometry in src, e.g.
+// the first vertex of a polygon's exterior ring, as a representative point
+// for previews. Only the bytes up to that coordinate are read, so it costs
+// the same for any geometry size. Empty geometries, such as POINT EMPTY,
+// have no coordinates and are an error.
+func PreviewPoint(src []byte) (orb.Point, error) {
+	var first orb.Point
+	w := ewkbWalker{
+		r: bytes.NewReader(src),
+		coord: func(p orb.Point) error {
+			// PostGIS encodes POINT EMPTY with NaN coordinates.
+			if math.IsNaN(p[0]) && math.IsNaN(p[1]) {
+				return nil
+			}
+			first = p
+			return errStopWalk
+		},
+	}
+
+	switch err := w.geometry(); {
+	case errors.Is(err, errStopWalk):
+		return first, nil
+	case err != nil:
+		return orb.Point{}, err
+	default:
+		return orb.Point{}, errors.New("geometry has no coordinates")
+	}
+}
+
+// contextCheckInterval is the number of members DecodeContext decodes between
+// checks of its context.
+const contextCheckInterval = 64
+
+// DecodeContext decodes the EWKB geometry in src like the scan plans, without
+// their options, aborting with the error of ctx once it is done. The context
+// is checked before decoding and periodically between the members of
+// multi-geometries and collections, so a cancelled request stops decoding a
+// large collection early; a single geometry, however large, is decoded
+// whole.
+func DecodeContext(ctx context.Context, src []byte) (orb.Geometry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	geom, n, err := decodeContext(ctx, src, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if n != len(src) {
+		return nil, fmt.Errorf("%d trailing bytes after ewkb geometry", len(src)-n)
+	}
+
+	return geom, nil
+}
+
+// decodeContext decodes the geometry at the start of src at depth, the
+// geometry decoded first being at depth 1, and returns the number of bytes it
+// consumed.
+func decodeContext(ctx context.Context, src []byte, depth int) (orb.Geometry, int, error) {
+	if depth > maxNestingDepth {
+		return nil, 0, classErrorf(ErrInvalidEWKB, "geometry nested deeper than %d levels", maxNestingDepth)
+	}
+
+	h, err := parseHeader(src)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch h.typ {
+	case multiPointType, multiLineStringType, multiPolygonType, geometryCollectionType:
+	default:
+		// A geometry without members is walked to find its end, as orb's
+		// decoder doesn't report it.
+		w := ewkbWalker{r: bytes.NewReader(src), depth: depth - 1}
+		if err := w.geometry(); err != nil {
+			return nil, 0, err
+		}
+
+		geom, _, err := unmarshalWithHeader(h, src[:w.consumed])
+		return geom, int(w.consumed), err
+	}
+
+	if err := h.checkSupported(); err != nil {
+		return nil, 0, err
+	}
+
+	members, n, err := decodeMembersContext(ctx, h, src, depth)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch h.typ {
+	case multiPointType:
+		points, err := assertMembers[orb.Point](members)
+		return orb.MultiPoint(points), n, err
+	case multiLineStringType:
+		lines, err := assertMembers[orb.LineString](members)
+		return orb.MultiLineString(lines), n, err
+	case multiPolygonType:
+		polys, err := assertMembers[orb.Polygon](members)
+		return orb.MultiPolygon(polys), n, err
+	default:
+		return orb.Collection(members), n, nil
+	}
+}
+
+// decodeMembersContext decodes the members of the multi-geometry or
+// collection at depth at the start of src, whose header is h, checking ctx
+// periodically, and returns the number of bytes the geometry consumed. Each
+// member is decoded in a single pass, which returns where the next one
+// starts.
+func decodeMembersContext(ctx context.Context, h ewkbHeader, src []byte, depth int) ([]orb.Geometry, int, error) {
+	if len(src) < h.size+4 {
+		return nil, 0, classErrorf(ErrInvalidEWKB, "ewkb geometry too short for member count: %d bytes", len(src))
+	}
+	n := h.order.Uint32(src[h.size:])
+	consumed := h.size + 4
+
+	// The count isn't trusted for the capacity, as a corrupt one would
+	// exhaust memory.
+	members := make([]orb.Geometry, 0, min(n, contextCheckInterval))
+	for i := range n {
+		if i%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		member, size, err := decodeContext(ctx, src[consumed:], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		members = append(members, member)
+		consumed += size
+	}
+
+	return members, consumed, nil
+}
+
+// assertMembers returns the members of a multi-geometry as their type G.
+func assertMembers[G orb.Geometry](members []orb.Geometry) ([]G, error) {
+	typed := make([]G, len(members))
+	for i, member := range members {
+		g, ok := member.(G)
+		if !ok {
+			return nil, classErrorf(ErrInvalidEWKB, "unexpected %s member in multi-geometry", member.GeoJSONType())
+		}
+		typed[i] = g
+	}
+
+	return typed, nil
+}
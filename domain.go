@@ -0,0 +1,63 @@
+package pgxorb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// domainsQuery lists the domains over the types with the given OIDs, including
+// domains over those domains, along with the OID of the type at their base.
+const domainsQuery = `with recursive domains(oid, name, base) as (
+	select oid, typname, typbasetype from pg_type
+	where typtype = 'd' and typbasetype = any($1::oid[])
+	union all
+	select t.oid, t.typname, d.base from pg_type t
+	join domains d on t.typbasetype = d.oid
+	where t.typtype = 'd'
+)
+select oid, name, base from domains`
+
+// registerDomains registers the codecs of the geometry and geography types,
+// already registered on conn, under the OIDs of the domains over them.
+func registerDomains(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	var bases []uint32
+	for _, name := range []string{"geometry", "geography"} {
+		if t, ok := conn.TypeMap().TypeForName(name); ok {
+			bases = append(bases, t.OID)
+		}
+	}
+
+	rows, err := conn.Query(ctx, domainsQuery, bases)
+	if err != nil {
+		return fmt.Errorf("get geometry domains failed on %s: %w", describeConn(conn), err)
+	}
+
+	type domain struct {
+		OID  uint32
+		Name string
+		Base uint32
+	}
+	domains, err := pgx.CollectRows(rows, pgx.RowToStructByPos[domain])
+	if err != nil {
+		return fmt.Errorf("get geometry domains failed on %s: %w", describeConn(conn), err)
+	}
+
+	for _, d := range domains {
+		base, ok := conn.TypeMap().TypeForOID(d.Base)
+		if !ok {
+			continue
+		}
+
+		conn.TypeMap().RegisterType(&pgtype.Type{
+			Name:  d.Name,
+			Codec: base.Codec,
+			OID:   d.OID,
+		})
+		cfg.debug(ctx, "registered codec", "type", d.Name, "oid", d.OID, "base", base.Name)
+	}
+
+	return nil
+}
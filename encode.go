@@ -0,0 +1,199 @@
+package pgxorb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+
+	"github.com/paulmach/orb"
+)
+
+// Sizes of the fixed parts of an EWKB geometry.
+const (
+	ewkbTypeWordSize = 1 + 4
+	ewkbSRIDSize     = 4
+	ewkbCountSize    = 4
+	ewkbPointSize    = 2 * 8
+)
+
+// appendEWKB appends the EWKB of geom with srid to buf, producing the same
+// bytes as [github.com/paulmach/orb/encoding/ewkb.Marshal]. The encoded size
+// is computed up front, so buf grows at most once and is written in place
+// when its capacity suffices. An SRID of 0 yields plain WKB. It returns buf
+// unchanged when geom holds no geometry.
+func appendEWKB(buf []byte, geom orb.Geometry, srid int, order binary.ByteOrder) ([]byte, error) {
+	geom = normalizeGeometry(geom)
+	if geom == nil {
+		return buf, nil
+	}
+
+	size, err := ewkbSize(geom, srid != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = slices.Grow(buf, size)
+	w := ewkbWriter{buf: buf, order: order}
+	w.geometry(geom, srid)
+
+	return w.buf, nil
+}
+
+// normalizeGeometry maps the geometries EWKB has no type for onto the ones it
+// encodes them as, and nil slice geometries to nil as nothing is written for
+// them.
+func normalizeGeometry(geom orb.Geometry) orb.Geometry {
+	if v := reflect.ValueOf(geom); v.Kind() == reflect.Slice && v.IsNil() {
+		return nil
+	}
+
+	switch g := geom.(type) {
+	case orb.Ring:
+		return orb.Polygon{g}
+	case orb.Bound:
+		return g.ToPolygon()
+	}
+
+	return geom
+}
+
+// ewkbSize returns the number of bytes the EWKB of the normalized geom
+// occupies, with room for an SRID when withSRID is set.
+func ewkbSize(geom orb.Geometry, withSRID bool) (int, error) {
+	size := ewkbTypeWordSize
+	if withSRID {
+		size += ewkbSRIDSize
+	}
+
+	switch g := geom.(type) {
+	case orb.Point:
+		return size + ewkbPointSize, nil
+	case orb.MultiPoint:
+		return size + ewkbCountSize + len(g)*(ewkbTypeWordSize+ewkbPointSize), nil
+	case orb.LineString:
+		return size + ewkbCountSize + len(g)*ewkbPointSize, nil
+	case orb.Polygon:
+		size += ewkbCountSize
+		for _, r := range g {
+			size += ewkbCountSize + len(r)*ewkbPointSize
+		}
+		return size, nil
+	case orb.MultiLineString:
+		return membersSize(size, g)
+	case orb.MultiPolygon:
+		return membersSize(size, g)
+	case orb.Collection:
+		return membersSize(size, g)
+	default:
+		return 0, fmt.Errorf("unsupported geometry type %T", geom)
+	}
+}
+
+// membersSize adds the sizes of the members of a multi-geometry, which are
+// written without an SRID, to the header size of the multi-geometry.
+func membersSize[G orb.Geometry](size int, members []G) (int, error) {
+	size += ewkbCountSize
+	for _, member := range members {
+		normalized := normalizeGeometry(member)
+		if normalized == nil {
+			return 0, fmt.Errorf("nil member in multi-geometry or collection")
+		}
+
+		memberSize, err := ewkbSize(normalized, false)
+		if err != nil {
+			return 0, err
+		}
+		size += memberSize
+	}
+
+	return size, nil
+}
+
+// An ewkbWriter appends EWKB to a buffer sized by ewkbSize.
+type ewkbWriter struct {
+	buf   []byte
+	order binary.ByteOrder
+}
+
+func (w *ewkbWriter) geometry(geom orb.Geometry, srid int) {
+	switch g := geom.(type) {
+	case orb.Point:
+		w.header(pointType, srid)
+		w.point(g)
+	case orb.MultiPoint:
+		w.header(multiPointType, srid)
+		writeMembers(w, g)
+	case orb.LineString:
+		w.header(lineStringType, srid)
+		w.points(g)
+	case orb.MultiLineString:
+		w.header(multiLineStringType, srid)
+		writeMembers(w, g)
+	case orb.Polygon:
+		w.header(polygonType, srid)
+		w.uint32(uint32(len(g)))
+		for _, r := range g {
+			w.points(r)
+		}
+	case orb.MultiPolygon:
+		w.header(multiPolygonType, srid)
+		writeMembers(w, g)
+	case orb.Collection:
+		w.header(geometryCollectionType, srid)
+		writeMembers(w, g)
+	}
+}
+
+// writeMembers writes a count followed by the members of a multi-geometry,
+// each as a geometry of its own without an SRID.
+func writeMembers[G orb.Geometry](w *ewkbWriter, members []G) {
+	w.uint32(uint32(len(members)))
+	for _, member := range members {
+		w.geometry(normalizeGeometry(member), 0)
+	}
+}
+
+// header writes the byte order marker and type word, flagging and appending
+// a non-zero srid.
+func (w *ewkbWriter) header(typ uint32, srid int) {
+	if w.order == binary.LittleEndian {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+
+	if srid == 0 {
+		w.uint32(typ)
+		return
+	}
+
+	w.uint32(typ | ewkbSRIDFlag)
+	w.uint32(uint32(srid))
+}
+
+// points writes a count followed by the coordinates of points.
+func (w *ewkbWriter) points(points []orb.Point) {
+	w.uint32(uint32(len(points)))
+	for _, p := range points {
+		w.point(p)
+	}
+}
+
+func (w *ewkbWriter) point(p orb.Point) {
+	w.uint64(math.Float64bits(p[0]))
+	w.uint64(math.Float64bits(p[1]))
+}
+
+func (w *ewkbWriter) uint32(v uint32) {
+	n := len(w.buf)
+	w.buf = w.buf[:n+4]
+	w.order.PutUint32(w.buf[n:], v)
+}
+
+func (w *ewkbWriter) uint64(v uint64) {
+	n := len(w.buf)
+	w.buf = w.buf[:n+8]
+	w.order.PutUint64(w.buf[n:], v)
+}
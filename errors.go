@@ -0,0 +1,122 @@
+package pgxorb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/paulmach/orb/encoding/ewkb"
+)
+
+// ErrTypeMismatch is wrapped by scan errors when the decoded geometry isn't
+// assignable to the concrete scan target, e.g. a POLYGON scanned into an
+// [orb.Point]. Scanning into an [orb.Geometry] accepts any geometry instead.
+var ErrTypeMismatch = errors.New("geometry type mismatch")
+
+// ErrProxiedConn is returned by registration with [WithDetectPooler] when the
+// connection appears to go through a pooler such as PgBouncer.
+var ErrProxiedConn = errors.New("connection is proxied by a pooler")
+
+// ErrUnsupportedPostGIS is returned by registration with
+// [WithMinPostGISVersion] when the PostGIS version of the server is older
+// than the minimum.
+var ErrUnsupportedPostGIS = errors.New("unsupported postgis version")
+
+// ErrSelfTestFailed is returned by registration with [WithSelfTest] when a
+// geometry doesn't survive the round trip through the server.
+var ErrSelfTestFailed = errors.New("self-test failed")
+
+// ErrOIDChanged is returned by registration with [WithOIDChangeCheck] when
+// the geometry OID of a database changed since it was first registered.
+var ErrOIDChanged = errors.New("geometry oid changed")
+
+// ErrInvalidEWKB is wrapped by decode errors when the bytes aren't a well
+// formed EWKB geometry, e.g. a truncated value or a bad byte order marker.
+var ErrInvalidEWKB = errors.New("invalid ewkb")
+
+// ErrUnsupportedGeometry is wrapped by decode errors for geometries orb can't
+// represent, such as a TIN or an ISO WKB geometry with Z coordinates.
+var ErrUnsupportedGeometry = errors.New("unsupported geometry")
+
+// ErrCurvedGeometry is wrapped by decode errors for geometries with circular
+// arcs, such as a CIRCULARSTRING, which orb can't represent.
+var ErrCurvedGeometry = errors.New("curved geometry")
+
+// ErrSRIDNotAllowed is wrapped by decode errors with [WithAllowedSRIDs] for
+// geometries whose SRID isn't allowed.
+var ErrSRIDNotAllowed = errors.New("srid not allowed")
+
+// A classError is an error of the class given by a sentinel, which it
+// unwraps to, with a message of its own.
+type classError struct {
+	class error
+	msg   string
+}
+
+// classErrorf returns an error of class formatted according to format.
+func classErrorf(class error, format string, args ...any) error {
+	return &classError{class: class, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *classError) Error() string {
+	return e.msg
+}
+
+func (e *classError) Unwrap() error {
+	return e.class
+}
+
+// ErrNot2D is wrapped by decode errors with [WithStrict2D] for geometries
+// with Z or M coordinates.
+var ErrNot2D = errors.New("geometry not 2d")
+
+// invalidEWKBAdvice is the advice for values that aren't EWKB, whether
+// rejected by this package or by orb.
+const invalidEWKBAdvice = "The value isn't a PostGIS geometry. " +
+	"Check that the column is of type geometry or geography; for bytea or text columns use DecodeBytea or ParseWKT."
+
+// friendlyErrors maps the known error classes to advice on resolving them,
+// most specific first.
+var friendlyErrors = []struct {
+	class  error
+	advice string
+}{
+	{ErrTypeMismatch, "The column holds a different geometry type than the scan target. " +
+		"Scan into an orb.Geometry to accept any type, or filter the rows with GeometryType(geom) in the query."},
+	{ErrCurvedGeometry, "The geometry has curved segments, which orb can't represent. " +
+		"Linearize it in the query with ST_CurveToLine(geom)."},
+	{ErrUnsupportedGeometry, "The geometry type can't be represented by orb. " +
+		"Convert it in the query, e.g. with ST_Force2D(geom) or (ST_Dump(geom)).geom."},
+	{ErrNot2D, "The geometry has Z or M coordinates, but the application expects plain 2D geometries. " +
+		"Drop them in the query with ST_Force2D(geom)."},
+	{ErrSRIDNotAllowed, "The geometry is in a coordinate system the application doesn't accept. " +
+		"Reproject it in the query with ST_Transform(geom, srid) to an allowed SRID."},
+	{ErrInvalidEWKB, invalidEWKBAdvice},
+	{ewkb.ErrNotEWKB, invalidEWKBAdvice},
+	{ewkb.ErrIncorrectGeometry, invalidEWKBAdvice},
+	{ErrUnsupportedPostGIS, "The PostGIS extension of the server is too old for this application. " +
+		"Upgrade it, then run ALTER EXTENSION postgis UPDATE."},
+	{ErrSelfTestFailed, "The codecs don't round-trip a geometry through the server. " +
+		"Check the OIDs given with WithTypeOID or WithOIDQuery and that the postgis extension is installed."},
+	{ErrOIDChanged, "The postgis extension was recreated while the application was running. " +
+		"Restart the application so every connection and prepared statement uses the new OIDs."},
+	{ErrProxiedConn, "The connection goes through a transaction pooler such as PgBouncer. " +
+		"Connect to PostgreSQL directly, or re-register on every acquire with Registrar.BeforeAcquire."},
+}
+
+// FriendlyError describes err, as returned by this package or by pgx while
+// encoding or scanning a geometry, with advice on how to resolve it, for
+// showing to users unfamiliar with EWKB. Errors of unknown classes are
+// described by their own message, and a nil err by an empty string.
+func FriendlyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, e := range friendlyErrors {
+		if errors.Is(err, e.class) {
+			return fmt.Sprintf("%s (%v)", e.advice, err)
+		}
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,10 @@
+package pgxorb
+
+import "github.com/jackc/pgx/v5/pgtype"
+
+// NewScanPlan plans a scan into target in format for a codec configured by
+// opts, bypassing the scan plan cache of the codec, so benchmarks can compare
+// against it.
+func NewScanPlan(format int16, target any, opts ...Option) pgtype.ScanPlan {
+	return newScanPlan(newConfig(opts...), format, target)
+}
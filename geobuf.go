@@ -0,0 +1,519 @@
+package pgxorb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// Geobuf geometry types.
+const (
+	geobufPoint = iota
+	geobufMultiPoint
+	geobufLineString
+	geobufMultiLineString
+	geobufPolygon
+	geobufMultiPolygon
+	geobufCollection
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// DecodeGeobuf decodes the features of a Geobuf message, as produced by
+// ST_AsGeobuf, into a feature collection. A message holding a single feature
+// or geometry decodes to a collection of one feature. Feature properties and
+// ids are decoded too; numbers are float64, as decoded from GeoJSON, and JSON
+// values are unmarshaled. Z and M coordinates are dropped.
+func DecodeGeobuf(src []byte) (*geojson.FeatureCollection, error) {
+	d := geobufDecoder{dims: 2, precision: 6}
+
+	var (
+		dataField int
+		data      []byte
+	)
+	err := eachField(src, func(field, wire int, r *pbReader) error {
+		switch field {
+		case 1:
+			key, err := r.bytes(wire)
+			d.keys = append(d.keys, string(key))
+			return err
+		case 2:
+			v, err := r.varint(wire)
+			d.dims = int(v)
+			return err
+		case 3:
+			v, err := r.varint(wire)
+			d.precision = int(v)
+			return err
+		case 4, 5, 6:
+			dataField = field
+			var err error
+			data, err = r.bytes(wire)
+			return err
+		default:
+			return r.skip(wire)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid geobuf: %w", err)
+	}
+
+	if d.dims < 2 {
+		return nil, fmt.Errorf("invalid geobuf: %d dimensions", d.dims)
+	}
+	d.scale = math.Pow10(d.precision)
+
+	fc := geojson.NewFeatureCollection()
+	switch dataField {
+	case 4:
+		err = eachField(data, func(field, wire int, r *pbReader) error {
+			if field != 1 {
+				return r.skip(wire)
+			}
+
+			raw, err := r.bytes(wire)
+			if err != nil {
+				return err
+			}
+
+			f, err := d.feature(raw)
+			if err != nil {
+				return err
+			}
+			fc.Append(f)
+
+			return nil
+		})
+	case 5:
+		var f *geojson.Feature
+		if f, err = d.feature(data); err == nil {
+			fc.Append(f)
+		}
+	case 6:
+		var geom orb.Geometry
+		if geom, err = d.geometry(data); err == nil {
+			fc.Append(geojson.NewFeature(geom))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid geobuf: %w", err)
+	}
+
+	return fc, nil
+}
+
+// A geobufDecoder decodes the features of a Geobuf message with the keys,
+// dimensions and precision it declares.
+type geobufDecoder struct {
+	keys      []string
+	dims      int
+	precision int
+	scale     float64
+}
+
+func (d *geobufDecoder) feature(src []byte) (*geojson.Feature, error) {
+	f := geojson.NewFeature(nil)
+
+	var (
+		values     []any
+		properties []uint64
+	)
+	err := eachField(src, func(field, wire int, r *pbReader) error {
+		switch field {
+		case 1:
+			raw, err := r.bytes(wire)
+			if err != nil {
+				return err
+			}
+			f.Geometry, err = d.geometry(raw)
+			return err
+		case 11:
+			id, err := r.bytes(wire)
+			f.ID = string(id)
+			return err
+		case 12:
+			id, err := r.varint(wire)
+			f.ID = float64(zigzag(id))
+			return err
+		case 13:
+			raw, err := r.bytes(wire)
+			if err != nil {
+				return err
+			}
+			v, err := geobufValue(raw)
+			values = append(values, v)
+			return err
+		case 14:
+			var err error
+			properties, err = r.packed(wire, properties)
+			return err
+		default:
+			return r.skip(wire)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Properties are pairs of indexes into the keys and the values.
+	if len(properties)%2 != 0 {
+		return nil, errors.New("odd number of property indexes")
+	}
+	for i := 0; i < len(properties); i += 2 {
+		k, v := properties[i], properties[i+1]
+		if k >= uint64(len(d.keys)) || v >= uint64(len(values)) {
+			return nil, fmt.Errorf("property index %d:%d out of range", k, v)
+		}
+		f.Properties[d.keys[k]] = values[v]
+	}
+
+	return f, nil
+}
+
+// geobufValue decodes a property value.
+func geobufValue(src []byte) (any, error) {
+	var value any
+	err := eachField(src, func(field, wire int, r *pbReader) error {
+		switch field {
+		case 1:
+			s, err := r.bytes(wire)
+			value = string(s)
+			return err
+		case 2:
+			v, err := r.fixed64(wire)
+			value = math.Float64frombits(v)
+			return err
+		case 3:
+			v, err := r.varint(wire)
+			value = float64(v)
+			return err
+		case 4:
+			v, err := r.varint(wire)
+			value = -float64(v)
+			return err
+		case 5:
+			v, err := r.varint(wire)
+			value = v != 0
+			return err
+		case 6:
+			raw, err := r.bytes(wire)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(raw, &value)
+		default:
+			return r.skip(wire)
+		}
+	})
+
+	return value, err
+}
+
+func (d *geobufDecoder) geometry(src []byte) (orb.Geometry, error) {
+	var (
+		geomType int
+		lengths  []uint64
+		coords   []uint64
+		members  [][]byte
+	)
+	err := eachField(src, func(field, wire int, r *pbReader) error {
+		var err error
+		switch field {
+		case 1:
+			var v uint64
+			v, err = r.varint(wire)
+			geomType = int(v)
+		case 2:
+			lengths, err = r.packed(wire, lengths)
+		case 3:
+			coords, err = r.packed(wire, coords)
+		case 4:
+			var raw []byte
+			raw, err = r.bytes(wire)
+			members = append(members, raw)
+		default:
+			err = r.skip(wire)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := geobufCoords{coords: coords, dims: d.dims, scale: d.scale}
+	switch geomType {
+	case geobufPoint:
+		return c.point()
+	case geobufMultiPoint:
+		points, err := c.line(c.remaining(), false)
+		return orb.MultiPoint(points), err
+	case geobufLineString:
+		points, err := c.line(c.remaining(), false)
+		return orb.LineString(points), err
+	case geobufMultiLineString:
+		if lengths == nil {
+			points, err := c.line(c.remaining(), false)
+			return orb.MultiLineString{points}, err
+		}
+		mls := make(orb.MultiLineString, len(lengths))
+		for i, n := range lengths {
+			if mls[i], err = c.line(n, false); err != nil {
+				return nil, err
+			}
+		}
+		return mls, nil
+	case geobufPolygon:
+		if lengths == nil {
+			lengths = []uint64{c.remaining()}
+		}
+		return c.polygon(lengths)
+	case geobufMultiPolygon:
+		if lengths == nil {
+			p, err := c.polygon([]uint64{c.remaining()})
+			return orb.MultiPolygon{p}, err
+		}
+		return c.multiPolygon(lengths)
+	case geobufCollection:
+		gc := make(orb.Collection, len(members))
+		for i, raw := range members {
+			if gc[i], err = d.geometry(raw); err != nil {
+				return nil, err
+			}
+		}
+		return gc, nil
+	default:
+		return nil, fmt.Errorf("unknown geometry type %d", geomType)
+	}
+}
+
+// geobufCoords reads the zigzag encoded integer coordinates of a geometry.
+type geobufCoords struct {
+	coords []uint64
+	dims   int
+	scale  float64
+}
+
+// remaining returns the number of points left.
+func (c *geobufCoords) remaining() uint64 {
+	return uint64(len(c.coords) / c.dims)
+}
+
+// point reads a point, whose coordinates are absolute.
+func (c *geobufCoords) point() (orb.Point, error) {
+	if len(c.coords) < c.dims {
+		return orb.Point{}, errors.New("missing point coordinates")
+	}
+
+	p := orb.Point{c.value(zigzag(c.coords[0])), c.value(zigzag(c.coords[1]))}
+	c.coords = c.coords[c.dims:]
+
+	return p, nil
+}
+
+// line reads n points, each stored as the difference to the previous one.
+// The closing point of a ring, which is left out, is added back with closed.
+func (c *geobufCoords) line(n uint64, closed bool) ([]orb.Point, error) {
+	if n > c.remaining() {
+		return nil, fmt.Errorf("%d points exceed the %d left", n, c.remaining())
+	}
+
+	points := make([]orb.Point, n, n+1)
+	var x, y int64
+	for i := range points {
+		x += zigzag(c.coords[0])
+		y += zigzag(c.coords[1])
+		points[i] = orb.Point{c.value(x), c.value(y)}
+		c.coords = c.coords[c.dims:]
+	}
+
+	if closed && n > 0 {
+		points = append(points, points[0])
+	}
+
+	return points, nil
+}
+
+// polygon reads the rings of the given lengths.
+func (c *geobufCoords) polygon(lengths []uint64) (orb.Polygon, error) {
+	p := make(orb.Polygon, len(lengths))
+	for i, n := range lengths {
+		ring, err := c.line(n, true)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = ring
+	}
+
+	return p, nil
+}
+
+// multiPolygon reads the polygons described by lengths: their number, then
+// for each the number of its rings followed by their lengths.
+func (c *geobufCoords) multiPolygon(lengths []uint64) (orb.MultiPolygon, error) {
+	n, lengths := lengths[0], lengths[1:]
+	if n > uint64(len(lengths)) {
+		return nil, fmt.Errorf("%d polygons exceed the %d lengths left", n, len(lengths))
+	}
+
+	mp := make(orb.MultiPolygon, n)
+	for i := range mp {
+		if len(lengths) == 0 || lengths[0] >= uint64(len(lengths)) {
+			return nil, errors.New("missing ring lengths")
+		}
+		rings := lengths[0]
+
+		var err error
+		if mp[i], err = c.polygon(lengths[1 : 1+rings]); err != nil {
+			return nil, err
+		}
+		lengths = lengths[1+rings:]
+	}
+
+	return mp, nil
+}
+
+// value scales the integer coordinate v back by the precision.
+func (c *geobufCoords) value(v int64) float64 {
+	return float64(v) / c.scale
+}
+
+// zigzag decodes the zigzag encoding of a signed protobuf integer.
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// eachField calls fn with the number and wire type of every field of the
+// protobuf message in src, along with a reader positioned at its value, which
+// fn must consume.
+func eachField(src []byte, fn func(field, wire int, r *pbReader) error) error {
+	r := pbReader{src: src}
+	for len(r.src) > 0 {
+		key, err := r.uvarint()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(int(key>>3), int(key&7), &r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A pbReader reads the values of a protobuf message.
+type pbReader struct {
+	src []byte
+}
+
+func (r *pbReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.src)
+	if n <= 0 {
+		return 0, errors.New("truncated or overlong varint")
+	}
+	r.src = r.src[n:]
+
+	return v, nil
+}
+
+// varint reads a varint field value.
+func (r *pbReader) varint(wire int) (uint64, error) {
+	if wire != wireVarint {
+		return 0, fmt.Errorf("got wire type %d, want varint", wire)
+	}
+
+	return r.uvarint()
+}
+
+// fixed64 reads a 64-bit field value.
+func (r *pbReader) fixed64(wire int) (uint64, error) {
+	if wire != wireFixed64 {
+		return 0, fmt.Errorf("got wire type %d, want fixed64", wire)
+	}
+	if len(r.src) < 8 {
+		return 0, errors.New("truncated fixed64")
+	}
+
+	v := binary.LittleEndian.Uint64(r.src)
+	r.src = r.src[8:]
+
+	return v, nil
+}
+
+// bytes reads a length-delimited field value.
+func (r *pbReader) bytes(wire int) ([]byte, error) {
+	if wire != wireBytes {
+		return nil, fmt.Errorf("got wire type %d, want length-delimited", wire)
+	}
+
+	n, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.src)) {
+		return nil, fmt.Errorf("length %d exceeds the %d bytes left", n, len(r.src))
+	}
+
+	b := r.src[:n]
+	r.src = r.src[n:]
+
+	return b, nil
+}
+
+// packed appends the varints of a repeated field to values, whether packed
+// or sent one by one.
+func (r *pbReader) packed(wire int, values []uint64) ([]uint64, error) {
+	if wire == wireVarint {
+		v, err := r.uvarint()
+		return append(values, v), err
+	}
+
+	b, err := r.bytes(wire)
+	if err != nil {
+		return nil, err
+	}
+
+	packed := pbReader{src: b}
+	for len(packed.src) > 0 {
+		v, err := packed.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// skip skips a field value of an unknown field.
+func (r *pbReader) skip(wire int) error {
+	var err error
+	switch wire {
+	case wireVarint:
+		_, err = r.uvarint()
+	case wireFixed64:
+		_, err = r.fixed64(wire)
+	case wireBytes:
+		_, err = r.bytes(wire)
+	case wireFixed32:
+		if len(r.src) < 4 {
+			return errors.New("truncated fixed32")
+		}
+		r.src = r.src[4:]
+	default:
+		return fmt.Errorf("unsupported wire type %d", wire)
+	}
+
+	return err
+}
@@ -0,0 +1,41 @@
+package pgxorb
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/paulmach/orb"
+)
+
+// Geography wraps an [orb.Geometry] that must be sent as a PostGIS geography
+// rather than a geometry. Both types share the EWKB wire format, so the
+// wrapper only matters where the parameter type is not known in advance,
+// e.g. with the simple protocol.
+type Geography struct {
+	Geometry orb.Geometry
+}
+
+// AsGeography marks geom to be encoded as a PostGIS geography value.
+func AsGeography(geom orb.Geometry) Geography {
+	return Geography{Geometry: geom}
+}
+
+func (g Geography) unwrap(srid int) (orb.Geometry, int, error) {
+	return g.Geometry, srid, nil
+}
+
+// defaultGeographySRID is the SRID geographies are encoded with by default,
+// WGS 84 as assumed by PostGIS for geographies without one.
+const defaultGeographySRID = 4326
+
+func registerGeography(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	geographyCfg := *cfg
+	geographyCfg.srid = cfg.geographySRID
+
+	if err := registerType(ctx, conn, &geographyCfg, "geography"); err != nil {
+		return err
+	}
+	conn.TypeMap().RegisterDefaultPgType(Geography{}, "geography")
+
+	return nil
+}
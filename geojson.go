@@ -0,0 +1,33 @@
+package pgxorb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// jsonbVersion is the version byte leading the binary format of jsonb.
+const jsonbVersion = 1
+
+// ScanGeoJSONB decodes a GeoJSON geometry stored in a json or jsonb column.
+// raw may be the JSON text or the binary jsonb wire format. A JSON null
+// decodes to a nil geometry.
+func ScanGeoJSONB(raw []byte) (orb.Geometry, error) {
+	if len(raw) > 0 && raw[0] == jsonbVersion {
+		raw = raw[1:]
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	g, err := geojson.UnmarshalGeometry(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode geojson geometry: %w", err)
+	}
+
+	return g.Geometry(), nil
+}
@@ -3,10 +3,14 @@ package pgxorb
 import (
 	"context"
 	"database/sql/driver"
-	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"reflect"
+	"slices"
+	"strconv"
+	"sync"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -16,21 +20,76 @@ import (
 
 var orgGeometryInterfaceType = reflect.TypeOf((*orb.Geometry)(nil)).Elem()
 
-type geometryCodec struct{}
+// coordsType is the type of the coordinates of a point, the underlying type
+// of orb.Point.
+var coordsType = reflect.TypeOf([2]float64{})
+
+// A scanPlanKey identifies the scan plans memoized by a geometryCodec.
+type scanPlanKey struct {
+	format     int16
+	targetType reflect.Type
+}
+
+type geometryCodec struct {
+	cfg *config
+	// scanPlans memoizes the plans returned by PlanScan per format and target
+	// type, as pgx plans once per query.
+	scanPlans sync.Map // map[scanPlanKey]pgtype.ScanPlan
+}
 
 // A geometryBinaryEncodePlan implements
 // [github.com/jackc/pgx/v5/pgtype.EncodePlan] for types in binary format.
-type geometryBinaryEncodePlan struct{}
+type geometryBinaryEncodePlan struct {
+	cfg *config
+}
 
 // A geometryTextEncodePlan implements
 // [github.com/jackc/pgx/v5/pgtype.EncodePlan] for types in text format.
-type geometryTextEncodePlan struct{}
+type geometryTextEncodePlan struct {
+	cfg *config
+}
 
 // A geometryBinaryScanPlan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan]
-type geometryBinaryScanPlan struct{}
+type geometryBinaryScanPlan struct {
+	cfg *config
+	// targetErr is the error resolved at plan time for the target type.
+	targetErr error
+}
 
 // A geometryTextScanPlan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan]
-type geometryTextScanPlan struct{}
+type geometryTextScanPlan struct {
+	cfg *config
+	// targetErr is the error resolved at plan time for the target type.
+	targetErr error
+}
+
+// A geometryWithSRIDBinaryScanPlan implements
+// [github.com/jackc/pgx/v5/pgtype.ScanPlan] for *[GeometryWithSRID] in binary
+// format.
+type geometryWithSRIDBinaryScanPlan struct {
+	cfg *config
+}
+
+// A geometryWithSRIDTextScanPlan implements
+// [github.com/jackc/pgx/v5/pgtype.ScanPlan] for *[GeometryWithSRID] in text
+// format.
+type geometryWithSRIDTextScanPlan struct {
+	cfg *config
+}
+
+// A geometryConvertBinaryScanPlan implements
+// [github.com/jackc/pgx/v5/pgtype.ScanPlan] in binary format for targets
+// handled by the configured [ScanConverter].
+type geometryConvertBinaryScanPlan struct {
+	cfg *config
+}
+
+// A geometryConvertTextScanPlan implements
+// [github.com/jackc/pgx/v5/pgtype.ScanPlan] in text format for targets
+// handled by the configured [ScanConverter].
+type geometryConvertTextScanPlan struct {
+	cfg *config
+}
 
 // FormatSupported implements
 // [github.com/jackc/pgx/v5/pgtype.Codec.FormatSupported].
@@ -51,13 +110,31 @@ func (c *geometryCodec) PreferredFormat() int16 {
 	return pgtype.BinaryFormatCode
 }
 
+// PreferredFormatFor returns the wire format the codec prefers for geom. It
+// is always the binary format: EWKB is at least as compact as its hex text
+// form for every geometry, so no size makes text cheaper on the wire. pgx
+// picks a parameter's format per type rather than per value, so this matches
+// what the registered codec reports for any geometry; wrap a geometry in a
+// [TextGeometry], e.g. with [TextBelow], to send it in text instead.
+func PreferredFormatFor(geom orb.Geometry) int16 {
+	return pgtype.BinaryFormatCode
+}
+
 // PlanEncode implements [github.com/jackc/pgx/v5/pgtype.Codec.PlanEncode].
 func (c *geometryCodec) PlanEncode(m *pgtype.Map, old uint32, format int16, value any) pgtype.EncodePlan {
+	if c.cfg.planHook != nil {
+		c.cfg.planHook(PlanEncode, format)
+	}
+
 	switch format {
 	case pgtype.BinaryFormatCode:
-		return geometryBinaryEncodePlan{}
+		// Declining the binary format makes pgx fall back to text.
+		if isTextGeometry(value) {
+			return nil
+		}
+		return geometryBinaryEncodePlan{cfg: c.cfg}
 	case pgtype.TextFormatCode:
-		return geometryTextEncodePlan{}
+		return geometryTextEncodePlan{cfg: c.cfg}
 	default:
 		return nil
 	}
@@ -65,11 +142,52 @@ func (c *geometryCodec) PlanEncode(m *pgtype.Map, old uint32, format int16, valu
 
 // PlanScan implements [github.com/jackc/pgx/v5/pgtype.Codec.PlanScan].
 func (c *geometryCodec) PlanScan(m *pgtype.Map, old uint32, format int16, target any) pgtype.ScanPlan {
+	if c.cfg.planHook != nil {
+		c.cfg.planHook(PlanScan, format)
+	}
+
+	key := scanPlanKey{format: format, targetType: reflect.TypeOf(target)}
+	if plan, ok := c.scanPlans.Load(key); ok {
+		return plan.(pgtype.ScanPlan)
+	}
+
+	plan := newScanPlan(c.cfg, format, target)
+	if plan == nil {
+		return nil
+	}
+
+	c.scanPlans.Store(key, plan)
+
+	return plan
+}
+
+// newScanPlan returns the scan plan for target in format, dispatching
+// *GeometryWithSRID targets to the plans keeping the SRID and the targets
+// accepted by a ScanConverter to it.
+func newScanPlan(cfg *config, format int16, target any) pgtype.ScanPlan {
+	var binaryPlan, textPlan pgtype.ScanPlan
+	switch _, withSRID := target.(*GeometryWithSRID); {
+	case withSRID:
+		binaryPlan = &geometryWithSRIDBinaryScanPlan{cfg: cfg}
+		textPlan = &geometryWithSRIDTextScanPlan{cfg: cfg}
+	case cfg.converter != nil && target != nil && cfg.converter.CanScan(reflect.TypeOf(target)):
+		binaryPlan = &geometryConvertBinaryScanPlan{cfg: cfg}
+		textPlan = &geometryConvertTextScanPlan{cfg: cfg}
+	case cfg.factory == nil && isPointerToPointer(target):
+		// pgx dereferences the target, leaving it nil for NULL, and plans
+		// again for the pointer it allocates.
+		return nil
+	default:
+		targetErr := planScanTarget(cfg, target)
+		binaryPlan = &geometryBinaryScanPlan{cfg: cfg, targetErr: targetErr}
+		textPlan = &geometryTextScanPlan{cfg: cfg, targetErr: targetErr}
+	}
+
 	switch format {
 	case pgx.BinaryFormatCode:
-		return geometryBinaryScanPlan{}
+		return binaryPlan
 	case pgx.TextFormatCode:
-		return geometryTextScanPlan{}
+		return textPlan
 	default:
 		return nil
 	}
@@ -91,14 +209,17 @@ func (c *geometryCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src
 	switch format {
 	case pgtype.TextFormatCode:
 		var err error
-		src, err = hex.DecodeString(string(src))
+		src, err = decodeHex(src)
 		if err != nil {
 			return nil, err
 		}
 		fallthrough
 	case pgtype.BinaryFormatCode:
-		geom, _, err := ewkb.Unmarshal(src)
-		return geom, err
+		geom, err := decodeGeometry(c.cfg, src)
+		if err != nil || c.cfg.factory == nil {
+			return geom, err
+		}
+		return c.cfg.factory(geom), nil
 	default:
 		return nil, errors.ErrUnsupported
 	}
@@ -106,116 +227,694 @@ func (c *geometryCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src
 
 // Encode implements [github.com/jackc/pgx/v5/pgtype.EncodePlan.Encode].
 func (p geometryBinaryEncodePlan) Encode(value any, buf []byte) (newBuf []byte, err error) {
-	geom, ok := value.(orb.Geometry)
-	if !ok {
-		return buf, errors.ErrUnsupported
+	return appendGeometry(p.cfg, buf, value)
+}
+
+// Encode implements [github.com/jackc/pgx/v5/pgtype.EncodePlan.Encode].
+func (p geometryTextEncodePlan) Encode(value any, buf []byte) (newBuf []byte, err error) {
+	hexBuf, err := marshalHex(p.cfg, value)
+	if err != nil || hexBuf == "" {
+		return nil, err
+	}
+
+	return append(buf, hexBuf...), nil
+}
+
+// marshalGeometry encodes value as EWKB using the SRID and byte order of cfg.
+// It returns nil when value holds no geometry and must be sent as NULL.
+func marshalGeometry(cfg *config, value any) ([]byte, error) {
+	return appendGeometry(cfg, nil, value)
+}
+
+// appendGeometry appends the EWKB of value, encoded using cfg, to buf. It
+// returns nil when value holds no geometry and must be sent as NULL.
+func appendGeometry(cfg *config, buf []byte, value any) ([]byte, error) {
+	if m, ok := value.(EWKBMarshaler); ok {
+		return appendMarshaled(cfg, buf, m)
+	}
+
+	geom, srid, err := resolveGeometry(cfg, value)
+	if err != nil || geom == nil {
+		return nil, err
+	}
+
+	if cfg.skipNilMembers {
+		geom = dropNilMembers(geom)
+	} else if err := checkNilMembers(geom); err != nil {
+		return nil, fmt.Errorf("failed to encode geometry: %w; leave them out with WithSkipNilMembers", err)
+	}
+
+	if encodesAsNull(cfg, geom) {
+		return nil, nil
+	}
+
+	if cfg.swapAxes {
+		geom, err = swapAxes(geom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to swap axes: %w", err)
+		}
+	}
+
+	if cfg.gridSize > 0 {
+		geom, err = snapToGrid(geom, cfg.gridSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snap to grid: %w", err)
+		}
 	}
 
-	ewkbBuf, err := ewkb.Marshal(geom, ewkb.DefaultSRID, ewkb.DefaultByteOrder)
+	if cfg.orientation != 0 {
+		geom = orientGeometry(geom, cfg.orientation)
+	}
+
+	n := len(buf)
+	buf, err = appendEWKB(buf, geom, srid, cfg.byteOrder)
 	if err != nil {
-		return buf, fmt.Errorf("failed to encode geometry: %w", err)
+		return nil, fmt.Errorf("failed to encode geometry: %w", err)
+	}
+
+	if len(buf) == n {
+		return nil, nil
+	}
+
+	return buf, nil
+}
+
+// encodesAsNull reports whether geom is to be sent as NULL by the options of
+// cfg.
+func encodesAsNull(cfg *config, geom orb.Geometry) bool {
+	return cfg.nonFiniteAsNull && !isFinite(geom) || cfg.emptyAsNull && isEmpty(geom)
+}
+
+// appendMarshaled appends the EWKB produced by m to buf. It returns nil when m
+// produces no bytes and must be sent as NULL.
+func appendMarshaled(cfg *config, buf []byte, m EWKBMarshaler) ([]byte, error) {
+	ewkbBuf, err := m.MarshalEWKB(cfg.srid, cfg.byteOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %T: %w", m, err)
+	}
+
+	if ewkbBuf == nil {
+		return nil, nil
 	}
 
 	return append(buf, ewkbBuf...), nil
 }
 
-// Encode implements [github.com/jackc/pgx/v5/pgtype.EncodePlan.Encode].
-func (p geometryTextEncodePlan) Encode(value any, buf []byte) (newBuf []byte, err error) {
+// resolveGeometry unwraps value into the geometry to encode and its SRID. It
+// returns a nil geometry when value must be sent as NULL.
+func resolveGeometry(cfg *config, value any) (orb.Geometry, int, error) {
+	value = derefValue(value)
+	srid := cfg.srid
+	if w, ok := value.(geometryWrapper); ok {
+		var err error
+		value, srid, err = w.unwrap(srid)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if f, ok := value.(Flattener); ok {
+		if !cfg.force2D {
+			return nil, 0, fmt.Errorf("%T has more than two dimensions; encode it with WithForce2D", value)
+		}
+		value = f.Flatten()
+	}
+
 	geom, ok := value.(orb.Geometry)
 	if !ok {
-		return buf, errors.ErrUnsupported
+		if value == nil {
+			return nil, 0, nil
+		}
+		return nil, 0, errors.ErrUnsupported
 	}
 
-	ewkbBuf, err := ewkb.Marshal(geom, ewkb.DefaultSRID, ewkb.DefaultByteOrder)
-	if err != nil {
-		return buf, fmt.Errorf("failed to encode geometry: %w", err)
+	return geom, srid, nil
+}
+
+// isPointerToPointer reports whether target is a pointer to a pointer, such as
+// **orb.Point.
+func isPointerToPointer(target any) bool {
+	t := reflect.TypeOf(target)
+	return t != nil && t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Pointer
+}
+
+// derefValue returns the value a pointer parameter such as *orb.Point points
+// to, or nil for a nil pointer, which is sent as NULL. pgx only dereferences
+// pointers itself for types its codecs reject.
+func derefValue(value any) any {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer {
+		return value
 	}
 
-	return append(buf, []byte(hex.EncodeToString(ewkbBuf))...), nil
+	if v.IsNil() {
+		return nil
+	}
+
+	return v.Elem().Interface()
 }
 
 // Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
-func (p geometryBinaryScanPlan) Scan(src []byte, target any) error {
-	targetType := reflect.TypeOf(target)
-	if targetType.Kind() != reflect.Ptr {
-		return fmt.Errorf("target must be a pointer to a orb.Geometry")
+func (p *geometryBinaryScanPlan) Scan(src []byte, target any) error {
+	dst, err := scanTarget(p.targetErr, target)
+	if err != nil {
+		return err
 	}
 
-	targetElem := targetType.Elem()
-	if !targetElem.Implements(orgGeometryInterfaceType) {
-		return fmt.Errorf("target must be a pointer to a orb.Geometry")
+	if len(src) == 0 {
+		return nil
+	}
+
+	geom, err := decodeGeometry(p.cfg, src)
+	if err != nil {
+		return err
+	}
+
+	return assignGeometry(p.cfg, dst, geom)
+}
+
+// Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
+func (p *geometryTextScanPlan) Scan(src []byte, target any) error {
+	dst, err := scanTarget(p.targetErr, target)
+	if err != nil {
+		return err
 	}
 
 	if len(src) == 0 {
 		return nil
 	}
 
-	geom, _, err := ewkb.Unmarshal(src)
+	src, err = decodeHex(src)
 	if err != nil {
 		return err
 	}
 
-	geomType := reflect.TypeOf(geom)
+	geom, err := decodeGeometry(p.cfg, src)
+	if err != nil {
+		return err
+	}
 
-	if targetType.Elem() != geomType {
-		return fmt.Errorf("target type %v doesn't match geometry type %v", targetType, geomType)
+	return assignGeometry(p.cfg, dst, geom)
+}
+
+// Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
+func (p *geometryWithSRIDBinaryScanPlan) Scan(src []byte, target any) error {
+	dst, ok := target.(*GeometryWithSRID)
+	if !ok || dst == nil {
+		return fmt.Errorf("target must be a non-nil *pgxorb.GeometryWithSRID, got %T", target)
+	}
+
+	if len(src) == 0 {
+		return nil
 	}
 
-	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(geom))
+	geom, srid, err := decodeGeometryWithSRID(p.cfg, src)
+	if err != nil {
+		return err
+	}
+	*dst = GeometryWithSRID{Geometry: geom, SRID: srid}
 
 	return nil
 }
 
 // Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
-func (p geometryTextScanPlan) Scan(src []byte, target any) error {
-	targetType := reflect.TypeOf(target)
-	if targetType.Kind() != reflect.Ptr {
-		return fmt.Errorf("target must be a pointer to a orb.Geometry")
+func (p *geometryWithSRIDTextScanPlan) Scan(src []byte, target any) error {
+	if len(src) == 0 {
+		return (&geometryWithSRIDBinaryScanPlan{cfg: p.cfg}).Scan(nil, target)
 	}
 
-	targetElem := targetType.Elem()
-	if !targetElem.Implements(orgGeometryInterfaceType) {
-		return fmt.Errorf("target must be a pointer to a orb.Geometry")
+	src, err := decodeHex(src)
+	if err != nil {
+		return err
+	}
+
+	return (&geometryWithSRIDBinaryScanPlan{cfg: p.cfg}).Scan(src, target)
+}
+
+// Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
+func (p *geometryConvertBinaryScanPlan) Scan(src []byte, target any) error {
+	if _, err := scanTarget(nil, target); err != nil {
+		return err
 	}
 
 	if len(src) == 0 {
 		return nil
 	}
 
-	var err error
-	src, err = hex.DecodeString(string(src))
+	geom, srid, err := decodeGeometryWithSRID(p.cfg, src)
 	if err != nil {
 		return err
 	}
 
-	geom, _, err := ewkb.Unmarshal(src)
+	return p.cfg.converter.ScanGeometry(geom, srid, target)
+}
+
+// Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
+func (p *geometryConvertTextScanPlan) Scan(src []byte, target any) error {
+	if len(src) == 0 {
+		return (&geometryConvertBinaryScanPlan{cfg: p.cfg}).Scan(nil, target)
+	}
+
+	src, err := decodeHex(src)
 	if err != nil {
 		return err
 	}
 
-	geomType := reflect.TypeOf(geom)
+	return (&geometryConvertBinaryScanPlan{cfg: p.cfg}).Scan(src, target)
+}
 
-	if targetType.Elem() != geomType {
-		return fmt.Errorf("target type %v doesn't match geometry type %v", targetType, geomType)
+// planScanTarget validates the type of target once for a scan plan.
+func planScanTarget(cfg *config, target any) error {
+	targetType := reflect.TypeOf(target)
+	if targetType == nil || targetType.Kind() != reflect.Ptr {
+		return fmt.Errorf("target must be a pointer to a orb.Geometry")
 	}
 
-	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(geom))
+	// With a factory the decoded value is whatever it returns, so the target
+	// can only be checked once the value is known. A [2]float64 receives the
+	// coordinates of points, as orb.Point is assignable to it.
+	if cfg.factory != nil || targetType.Elem().Implements(orgGeometryInterfaceType) ||
+		targetType.Elem() == coordsType {
+		return nil
+	}
+
+	if cfg.lenientScan && targetType.Elem().Kind() == reflect.Interface &&
+		orgGeometryInterfaceType.AssignableTo(targetType.Elem()) {
+		return nil
+	}
+
+	if slices.Contains(cfg.scanIfaces, targetType.Elem()) {
+		return nil
+	}
+
+	return fmt.Errorf("target must be a pointer to a orb.Geometry")
+}
+
+// scanTarget returns the value target points to, failing with the error
+// resolved for its type at plan time.
+func scanTarget(targetErr error, target any) (reflect.Value, error) {
+	if targetErr != nil {
+		return reflect.Value{}, targetErr
+	}
+
+	ptr := reflect.ValueOf(target)
+	if ptr.IsNil() {
+		return reflect.Value{}, fmt.Errorf("target must be a non-nil pointer, got nil %v", ptr.Type())
+	}
+
+	return ptr.Elem(), nil
+}
+
+// assignGeometry stores geom, converted by the configured factory if any,
+// into dst.
+func assignGeometry(cfg *config, dst reflect.Value, geom orb.Geometry) error {
+	value := scanValue(cfg, dst.Type(), geom)
+
+	valueType := reflect.TypeOf(value)
+	if valueType == nil || !valueType.AssignableTo(dst.Type()) {
+		return fmt.Errorf("%w: target type %v doesn't match geometry type %v",
+			ErrTypeMismatch, dst.Addr().Type(), valueType)
+	}
+
+	dst.Set(reflect.ValueOf(value))
 
 	return nil
 }
 
-func registerGeom(ctx context.Context, conn *pgx.Conn) error {
-	var geomtypeOID uint32
-	err := conn.QueryRow(ctx, "select 'geometry'::text::regtype::oid").Scan(&geomtypeOID)
+// scanValue returns the value stored for geom into a target of type t: geom
+// converted by the configured factory if any, or its single member when
+// WithSingletonMemberScan allows and only the member fits t.
+func scanValue(cfg *config, t reflect.Type, geom orb.Geometry) any {
+	if cfg.factory != nil {
+		return cfg.factory(geom)
+	}
+
+	if cfg.singletonMemberScan && geom != nil && !reflect.TypeOf(geom).AssignableTo(t) {
+		return collapseSingleton(geom)
+	}
+
+	return geom
+}
+
+// decodeGeometry decodes the EWKB in src and applies the decode options of
+// cfg.
+func decodeGeometry(cfg *config, src []byte) (orb.Geometry, error) {
+	geom, _, err := decodeGeometryWithSRID(cfg, src)
+	return geom, err
+}
+
+// decodeGeometryWithSRID is decodeGeometry also returning the SRID of the
+// geometry, served from the intern cache when configured. Interned geometries
+// are copied with WithCopyOnDecode; others are decoded afresh and never
+// shared.
+func decodeGeometryWithSRID(cfg *config, src []byte) (orb.Geometry, int, error) {
+	if cfg.intern == nil {
+		return decodeWithOptions(cfg, src)
+	}
+
+	geom, srid, ok := cfg.intern.get(src)
+	if !ok {
+		var err error
+		geom, srid, err = decodeWithOptions(cfg, src)
+		if err != nil {
+			return nil, 0, err
+		}
+		cfg.intern.put(src, geom, srid)
+	}
+
+	if cfg.copyOnDecode {
+		geom = orb.Clone(geom)
+	}
+
+	return geom, srid, nil
+}
+
+// decodeWithOptions decodes the EWKB in src and applies the decode options of
+// cfg, bypassing the intern cache. The header is parsed once and shared by
+// the checks of all options. It isn't memoized by the identity of src: pgx
+// reuses the row buffers, so the same slice holds a different value on the
+// next row.
+func decodeWithOptions(cfg *config, src []byte) (orb.Geometry, int, error) {
+	header, err := parseHeader(src)
 	if err != nil {
-		return fmt.Errorf("get geometry oid failed: %w", err)
+		return nil, 0, err
 	}
 
-	conn.TypeMap().RegisterType(&pgtype.Type{
+	if cfg.strict2D {
+		if err := header.checkStrict2D(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := checkAllowedSRID(cfg, header.srid); err != nil {
+		return nil, 0, err
+	}
+
+	if cfg.maxDepth > 0 && header.typ == geometryCollectionType {
+		if err := checkDepth(src, cfg.maxDepth); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	geom, srid, err := unmarshalWithHeader(header, src)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if cfg.swapAxesOnDecode {
+		geom, err = swapAxes(geom)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if cfg.removeRepeated || cfg.removeCollinear {
+		geom = removeRepeatedPoints(geom, cfg.removeCollinear)
+	}
+
+	if cfg.collapseSingletons {
+		geom = collapseSingleton(geom)
+	}
+
+	if cfg.simplifier != nil {
+		geom = cfg.simplifier.Simplify(geom)
+	}
+
+	return geom, srid, nil
+}
+
+// checkAllowedSRID reports an error if srid isn't allowed by cfg.
+func checkAllowedSRID(cfg *config, srid int) error {
+	if cfg.allowedSRIDs == nil {
+		return nil
+	}
+
+	if _, ok := cfg.allowedSRIDs[srid]; !ok {
+		return classErrorf(ErrSRIDNotAllowed, "geometry srid %d is not allowed", srid)
+	}
+
+	return nil
+}
+
+// unmarshalGeometry decodes an EWKB encoded geometry and its SRID. It is the
+// single decode entry point shared by the scan plans and exported helpers.
+func unmarshalGeometry(src []byte) (orb.Geometry, int, error) {
+	header, err := parseHeader(src)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return unmarshalWithHeader(header, src)
+}
+
+// unmarshalWithHeader is unmarshalGeometry for src whose header was already
+// parsed.
+func unmarshalWithHeader(header ewkbHeader, src []byte) (orb.Geometry, int, error) {
+	if err := header.checkSupported(); err != nil {
+		return nil, 0, err
+	}
+
+	// Members may embed bounding boxes even when the geometry doesn't.
+	if header.hasBBox || header.hasMembers() {
+		var err error
+		if src, err = stripBBoxes(src); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return ewkb.Unmarshal(src)
+}
+
+func registerGeom(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	if err := registerType(ctx, conn, cfg, "geometry"); err != nil {
+		return err
+	}
+	registerDefaultGeometryTypes(conn.TypeMap())
+
+	return nil
+}
+
+// registerDefaultGeometryTypes maps the parameter wrappers encoded as
+// geometries to the geometry type on m, for the simple protocol.
+func registerDefaultGeometryTypes(m *pgtype.Map) {
+	m.RegisterDefaultPgType(TypedGeometry{}, "geometry")
+	m.RegisterDefaultPgType(GeometryWithSRID{}, "geometry")
+	m.RegisterDefaultPgType(TransformedGeometry{}, "geometry")
+}
+
+// registerType registers the codec for the named type and its array type,
+// whose name is the type name prefixed with an underscore.
+func registerType(ctx context.Context, conn *pgx.Conn, cfg *config, name string) error {
+	elemType, err := loadType(ctx, conn, cfg, name)
+	if err != nil {
+		return err
+	}
+
+	arrayType, err := loadArrayType(ctx, conn, cfg, elemType)
+	if err != nil {
+		return err
+	}
+
+	conn.TypeMap().RegisterType(elemType)
+	conn.TypeMap().RegisterType(arrayType)
+	cfg.debug(ctx, "registered codec", "type", name, "oid", elemType.OID)
+	cfg.debug(ctx, "registered codec", "type", arrayType.Name, "oid", arrayType.OID)
+
+	return nil
+}
+
+// GeometryType returns the PostGIS geometry type with the codec configured by
+// opts, without registering it on conn, for code registering its types
+// generically. pgx can't build it with [github.com/jackc/pgx/v5.Conn.LoadType],
+// which only handles derived types; once it is registered, LoadType builds
+// the array type _geometry from it. Unlike [Register], it doesn't set up the
+// parameter wrappers for the simple protocol.
+func GeometryType(ctx context.Context, conn *pgx.Conn, opts ...Option) (*pgtype.Type, error) {
+	return loadType(ctx, conn, newConfig(opts...), "geometry")
+}
+
+// GeometryArrayType returns the PostGIS array type _geometry, its elements
+// decoded by the geometry codec configured by opts, without registering it
+// or the element type on conn. Registered on its own, it scans and encodes
+// geometry arrays, such as into a []orb.Geometry.
+func GeometryArrayType(ctx context.Context, conn *pgx.Conn, opts ...Option) (*pgtype.Type, error) {
+	cfg := newConfig(opts...)
+	elemType, err := loadType(ctx, conn, cfg, "geometry")
+	if err != nil {
+		return nil, err
+	}
+
+	return loadArrayType(ctx, conn, cfg, elemType)
+}
+
+// RegisterMap registers the geometry codec configured by opts on m under oid,
+// without querying a server, e.g. on the type map of every connection of a
+// pool with the OID resolved once by [GeometryType]. pgx memoizes plans in its
+// maps, so a map must not be shared by connections used concurrently. The
+// array type _geometry isn't registered; once geometry is,
+// [github.com/jackc/pgx/v5.Conn.LoadType] builds it.
+func RegisterMap(m *pgtype.Map, oid uint32, opts ...Option) {
+	m.RegisterType(&pgtype.Type{
 		Name:  "geometry",
-		Codec: &geometryCodec{},
-		OID:   geomtypeOID,
+		Codec: &geometryCodec{cfg: newConfig(opts...)},
+		OID:   oid,
 	})
+	registerDefaultGeometryTypes(m)
+}
 
-	return nil
+// loadType returns the named type with a codec configured by cfg.
+func loadType(ctx context.Context, conn *pgx.Conn, cfg *config, name string) (*pgtype.Type, error) {
+	oid, err := typeOID(ctx, conn, cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgtype.Type{
+		Name:  name,
+		Codec: &geometryCodec{cfg: cfg},
+		OID:   oid,
+	}, nil
+}
+
+// loadArrayType returns the array type of elemType, whose name is the element
+// type name prefixed with an underscore.
+func loadArrayType(ctx context.Context, conn *pgx.Conn, cfg *config, elemType *pgtype.Type) (*pgtype.Type, error) {
+	oid, err := typeOID(ctx, conn, cfg, "_"+elemType.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgtype.Type{
+		Name:  "_" + elemType.Name,
+		Codec: &pgtype.ArrayCodec{ElementType: elemType},
+		OID:   oid,
+	}, nil
+}
+
+// defaultOIDQuery resolves the OID of the type named by its only argument,
+// or NULL if there is none. Types not on the search path are looked up in
+// the schema the postgis extension was created in, such as a dedicated
+// extensions schema.
+const defaultOIDQuery = `select coalesce(to_regtype($1::text)::oid, (
+	select t.oid from pg_type t
+	join pg_extension e on e.extnamespace = t.typnamespace
+	where e.extname = 'postgis' and t.typname = $1::text
+))`
+
+// batchOIDQuery resolves the OIDs of the types named by its only argument, as
+// defaultOIDQuery does, leaving out the types that don't exist.
+const batchOIDQuery = `select name, oid from (
+	select name, coalesce(to_regtype(name)::oid, (
+		select t.oid from pg_type t
+		join pg_extension e on e.extnamespace = t.typnamespace
+		where e.extname = 'postgis' and t.typname = name
+	)) as oid
+	from unnest($1::text[]) as name
+) as types
+where oid is not null`
+
+// registeredTypeNames are the types [Register] resolves the OIDs of.
+var registeredTypeNames = []string{"geometry", "_geometry", "geography", "_geography", "box2d"}
+
+// optionalTypeNames are the types [Register] registers the codecs of only if
+// they exist. Their OIDs are resolved along with those of
+// registeredTypeNames, but never cause a query of their own.
+var optionalTypeNames = []string{"spheroid"}
+
+// prefetchTypeOIDs returns a copy of cfg presetting the OIDs of the registered
+// types, resolved in a single query. It returns cfg itself when all of them
+// are preset or its OID query was replaced, as a custom query resolves one
+// type at a time. Types that aren't found are left to typeOID, which reports
+// them, and optional types that aren't found are left unset.
+func prefetchTypeOIDs(ctx context.Context, conn *pgx.Conn, cfg *config) (*config, error) {
+	if cfg.oidQuery != defaultOIDQuery {
+		return cfg, nil
+	}
+
+	var names []string
+	for _, name := range registeredTypeNames {
+		if _, ok := cfg.typeOIDs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return cfg, nil
+	}
+	for _, name := range optionalTypeNames {
+		if _, ok := cfg.typeOIDs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	rows, err := conn.Query(ctx, batchOIDQuery, names)
+	if err != nil {
+		return nil, fmt.Errorf("get type oids failed on %s: %w", describeConn(conn), err)
+	}
+
+	prefetched := *cfg
+	prefetched.typeOIDs = maps.Clone(cfg.typeOIDs)
+	if prefetched.typeOIDs == nil {
+		prefetched.typeOIDs = make(map[string]uint32, len(names))
+	}
+
+	var (
+		name string
+		oid  uint32
+	)
+	_, err = pgx.ForEachRow(rows, []any{&name, &oid}, func() error {
+		prefetched.typeOIDs[name] = oid
+		cfg.debug(ctx, "resolved type oid", "type", name, "oid", oid)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get type oids failed on %s: %w", describeConn(conn), err)
+	}
+
+	return &prefetched, nil
+}
+
+// typeOID resolves the OID of the named type on conn, preferring an OID
+// preset in cfg over running the OID query.
+func typeOID(ctx context.Context, conn *pgx.Conn, cfg *config, name string) (uint32, error) {
+	oid, ok, err := lookupTypeOID(ctx, conn, cfg, name)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("get %s oid failed on %s: type %s does not exist", name, describeConn(conn), name)
+	}
+
+	return oid, nil
+}
+
+// lookupTypeOID is typeOID reporting whether the type exists rather than
+// failing when it doesn't, as when the OID query returns NULL or no row.
+func lookupTypeOID(ctx context.Context, conn *pgx.Conn, cfg *config, name string) (uint32, bool, error) {
+	if oid, ok := cfg.typeOIDs[name]; ok {
+		return oid, true, nil
+	}
+
+	var oid *uint32
+	err := conn.QueryRow(ctx, cfg.oidQuery, name).Scan(&oid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get %s oid failed on %s: %w", name, describeConn(conn), err)
+	}
+	if oid == nil {
+		return 0, false, nil
+	}
+	cfg.debug(ctx, "resolved type oid", "type", name, "oid", *oid)
+
+	return *oid, true, nil
+}
+
+// describeConn identifies the server and database of conn for error
+// messages, leaving out the password.
+func describeConn(conn *pgx.Conn) string {
+	config := conn.Config()
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port)))
+
+	return fmt.Sprintf("database %q at %s@%s", config.Database, config.User, addr)
 }
@@ -0,0 +1,22 @@
+module github.com/moeryomenko/pgxorb/gogeom
+
+go 1.24.4
+
+require (
+	github.com/jackc/pgx/v5 v5.7.5
+	github.com/moeryomenko/pgxorb v0.0.0-00010101000000-000000000000
+	github.com/paulmach/orb v0.11.1
+	github.com/twpayne/go-geom v1.6.1
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	go.mongodb.org/mongo-driver v1.11.4 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+)
+
+replace github.com/moeryomenko/pgxorb => ../
@@ -0,0 +1,151 @@
+// Package gogeom bridges pgxorb to [github.com/twpayne/go-geom], so geometry
+// columns can be scanned into go-geom types by code not using orb:
+//
+//	err := pgxorb.Register(ctx, conn, pgxorb.WithScanConverter(gogeom.Converter{}))
+//	...
+//	var p geom.Point
+//	err = conn.QueryRow(ctx, "select location from places").Scan(&p)
+package gogeom
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/moeryomenko/pgxorb"
+	"github.com/paulmach/orb"
+	"github.com/twpayne/go-geom"
+)
+
+var geomInterfaceType = reflect.TypeOf((*geom.T)(nil)).Elem()
+
+// scanTypes are the go-geom types a Converter scans into, besides geom.T.
+var scanTypes = map[reflect.Type]bool{
+	reflect.TypeOf(geom.Point{}):              true,
+	reflect.TypeOf(geom.LineString{}):         true,
+	reflect.TypeOf(geom.Polygon{}):            true,
+	reflect.TypeOf(geom.MultiPoint{}):         true,
+	reflect.TypeOf(geom.MultiLineString{}):    true,
+	reflect.TypeOf(geom.MultiPolygon{}):       true,
+	reflect.TypeOf(geom.GeometryCollection{}): true,
+}
+
+// A Converter is a [github.com/moeryomenko/pgxorb.ScanConverter] scanning
+// into pointers to the go-geom geometry types, such as *geom.Point, and into
+// *geom.T, which accepts any geometry. The SRID of the decoded geometry is
+// kept.
+type Converter struct{}
+
+// CanScan implements
+// [github.com/moeryomenko/pgxorb.ScanConverter.CanScan].
+func (Converter) CanScan(t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer {
+		return false
+	}
+
+	return t.Elem() == geomInterfaceType || scanTypes[t.Elem()]
+}
+
+// ScanGeometry implements
+// [github.com/moeryomenko/pgxorb.ScanConverter.ScanGeometry].
+func (Converter) ScanGeometry(g orb.Geometry, srid int, target any) error {
+	converted, err := FromOrb(g, srid)
+	if err != nil {
+		return err
+	}
+
+	dst := reflect.ValueOf(target).Elem()
+	if dst.Type() == geomInterfaceType {
+		dst.Set(reflect.ValueOf(converted))
+		return nil
+	}
+
+	value := reflect.ValueOf(converted).Elem()
+	if value.Type() != dst.Type() {
+		return fmt.Errorf("%w: target type %v doesn't match geometry type %T",
+			pgxorb.ErrTypeMismatch, dst.Addr().Type(), converted)
+	}
+	dst.Set(value)
+
+	return nil
+}
+
+// FromOrb converts the orb geometry g to the equivalent go-geom geometry with
+// the given SRID.
+func FromOrb(g orb.Geometry, srid int) (geom.T, error) {
+	switch g := g.(type) {
+	case orb.Point:
+		return geom.NewPointFlat(geom.XY, g[:]).SetSRID(srid), nil
+	case orb.MultiPoint:
+		return geom.NewMultiPointFlat(geom.XY, flatten(g)).SetSRID(srid), nil
+	case orb.LineString:
+		return geom.NewLineStringFlat(geom.XY, flatten(g)).SetSRID(srid), nil
+	case orb.MultiLineString:
+		flat, ends := flattenLines(g)
+		return geom.NewMultiLineStringFlat(geom.XY, flat, ends).SetSRID(srid), nil
+	case orb.Polygon:
+		flat, ends := flattenLines(g)
+		return geom.NewPolygonFlat(geom.XY, flat, ends).SetSRID(srid), nil
+	case orb.MultiPolygon:
+		return fromMultiPolygon(g).SetSRID(srid), nil
+	case orb.Collection:
+		return fromCollection(g, srid)
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %T", g)
+	}
+}
+
+func fromMultiPolygon(mp orb.MultiPolygon) *geom.MultiPolygon {
+	var (
+		flat  []float64
+		endss = make([][]int, 0, len(mp))
+	)
+	for _, p := range mp {
+		ends := make([]int, 0, len(p))
+		for _, r := range p {
+			flat = append(flat, flatten(r)...)
+			ends = append(ends, len(flat))
+		}
+		endss = append(endss, ends)
+	}
+
+	return geom.NewMultiPolygonFlat(geom.XY, flat, endss)
+}
+
+func fromCollection(c orb.Collection, srid int) (*geom.GeometryCollection, error) {
+	gc := geom.NewGeometryCollection()
+	for _, member := range c {
+		converted, err := FromOrb(member, srid)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := gc.Push(converted); err != nil {
+			return nil, err
+		}
+	}
+
+	return gc.SetSRID(srid), nil
+}
+
+// flatten returns the coordinates of points in the flat layout of go-geom.
+func flatten[P ~[]orb.Point](points P) []float64 {
+	flat := make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		flat = append(flat, p[0], p[1])
+	}
+
+	return flat
+}
+
+// flattenLines returns the coordinates of lines in the flat layout of go-geom,
+// along with the end offset of each line.
+func flattenLines[L ~[]P, P ~[]orb.Point](lines L) ([]float64, []int) {
+	var flat []float64
+	ends := make([]int, 0, len(lines))
+	for _, line := range lines {
+		flat = append(flat, flatten(line)...)
+		ends = append(ends, len(flat))
+	}
+
+	return flat, ends
+}
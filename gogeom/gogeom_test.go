@@ -0,0 +1,74 @@
+package gogeom_test
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/moeryomenko/pgxorb"
+	"github.com/moeryomenko/pgxorb/gogeom"
+	"github.com/paulmach/orb"
+	"github.com/twpayne/go-geom"
+)
+
+// geometryOID is the OID the codec is registered under. No server is
+// involved, so any OID not taken by a built-in type does.
+const geometryOID = 90000
+
+func TestConverter(t *testing.T) {
+	m := pgtype.NewMap()
+	pgxorb.RegisterMap(m, geometryOID, pgxorb.WithScanConverter(gogeom.Converter{}))
+
+	for _, format := range []int16{
+		pgtype.BinaryFormatCode,
+		pgtype.TextFormatCode,
+	} {
+		t.Run(strconv.Itoa(int(format)), func(t *testing.T) {
+			encode := func(g orb.Geometry) []byte {
+				t.Helper()
+				src, err := m.Encode(geometryOID, format, g, nil)
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return src
+			}
+
+			var point geom.Point
+			if err := m.Scan(geometryOID, format, encode(orb.Point{1, 2}), &point); err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+
+			if got := point.FlatCoords(); point.SRID() != 4326 || !slices.Equal(got, []float64{1, 2}) {
+				t.Errorf("got point %v with srid %d, want [1 2] with srid 4326", got, point.SRID())
+			}
+
+			line := encode(orb.LineString{{0, 0}, {1, 1}})
+
+			var anyGeom geom.T
+			if err := m.Scan(geometryOID, format, line, &anyGeom); err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+
+			ls, ok := anyGeom.(*geom.LineString)
+			if !ok || !slices.Equal(ls.FlatCoords(), []float64{0, 0, 1, 1}) {
+				t.Errorf("got %#v, want a line string from (0 0) to (1 1)", anyGeom)
+			}
+
+			var native orb.LineString
+			if err := m.Scan(geometryOID, format, line, &native); err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+
+			if !native.Equal(orb.LineString{{0, 0}, {1, 1}}) {
+				t.Errorf("got %v, want a line string from (0 0) to (1 1)", native)
+			}
+
+			err := m.Scan(geometryOID, format, line, &point)
+			if !errors.Is(err, pgxorb.ErrTypeMismatch) {
+				t.Errorf("got error %v, want %v", err, pgxorb.ErrTypeMismatch)
+			}
+		})
+	}
+}
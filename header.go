@@ -0,0 +1,206 @@
+package pgxorb
+
+import "encoding/binary"
+
+// Flags of the EWKB geometry type word.
+const (
+	ewkbZFlag    uint32 = 0x80000000
+	ewkbMFlag    uint32 = 0x40000000
+	ewkbSRIDFlag uint32 = 0x20000000
+	// ewkbBBoxFlag marks a bounding box following the SRID, as set by some
+	// older producers of PostGIS EWKB.
+	ewkbBBoxFlag uint32 = 0x10000000
+
+	ewkbFlagsMask = ewkbZFlag | ewkbMFlag | ewkbSRIDFlag | ewkbBBoxFlag
+)
+
+// isoDimsBase is the step between the ISO SQL/MM type codes of each
+// coordinate dimension: 1000s are Z, 2000s M and 3000s ZM geometries.
+const isoDimsBase = 1000
+
+// Geometry type codes shared by WKB and EWKB.
+const (
+	pointType              uint32 = 1
+	lineStringType         uint32 = 2
+	polygonType            uint32 = 3
+	multiPointType         uint32 = 4
+	multiLineStringType    uint32 = 5
+	multiPolygonType       uint32 = 6
+	geometryCollectionType uint32 = 7
+)
+
+// Geometry type codes PostGIS emits but orb can't represent.
+const (
+	circularStringType    uint32 = 8
+	compoundCurveType     uint32 = 9
+	curvePolygonType      uint32 = 10
+	multiCurveType        uint32 = 11
+	multiSurfaceType      uint32 = 12
+	polyhedralSurfaceType uint32 = 15
+	tinType               uint32 = 16
+	triangleType          uint32 = 17
+)
+
+// surfaceTypeHints maps the unsupported surface types to their names and a
+// suggestion on how to convert them server-side.
+var surfaceTypeHints = map[uint32]struct{ name, hint string }{
+	polyhedralSurfaceType: {"POLYHEDRALSURFACE", "decompose it into polygons with (ST_Dump(geom)).geom"},
+	tinType:               {"TIN", "decompose it with (ST_Dump(geom)).geom and convert the triangles to polygons"},
+	triangleType:          {"TRIANGLE", "convert it with ST_MakePolygon(ST_ExteriorRing(geom))"},
+}
+
+// curveTypeNames maps the unsupported curved types to their names.
+var curveTypeNames = map[uint32]string{
+	circularStringType: "CIRCULARSTRING",
+	compoundCurveType:  "COMPOUNDCURVE",
+	curvePolygonType:   "CURVEPOLYGON",
+	multiCurveType:     "MULTICURVE",
+	multiSurfaceType:   "MULTISURFACE",
+}
+
+// An ewkbHeader is the leading byte order and type word of an EWKB geometry,
+// followed by the optional SRID.
+type ewkbHeader struct {
+	order   binary.ByteOrder
+	typ     uint32
+	hasZ    bool
+	hasM    bool
+	hasSRID bool
+	hasBBox bool
+	// iso is set when the dimensions are given by an ISO type code rather
+	// than the EWKB flags.
+	iso  bool
+	srid int
+	// size is the number of bytes the header occupies, including an
+	// embedded bounding box.
+	size int
+}
+
+// parseHeader reads the header of the EWKB geometry at the start of src. The
+// dimensions may be given by the PostGIS flags or by an ISO SQL/MM type code,
+// and either may be combined with the SRID and bounding box flags.
+func parseHeader(src []byte) (ewkbHeader, error) {
+	h, err := parseTypeWord(src)
+	if err != nil {
+		return ewkbHeader{}, err
+	}
+
+	if h.hasSRID {
+		if len(src) < 9 {
+			return ewkbHeader{}, classErrorf(ErrInvalidEWKB, "ewkb header too short for srid: %d bytes", len(src))
+		}
+		h.setSRID(src[5:])
+	}
+
+	if h.hasBBox {
+		if len(src) < h.size+h.bboxSize() {
+			return ewkbHeader{}, classErrorf(ErrInvalidEWKB, "ewkb header too short for bounding box: %d bytes", len(src))
+		}
+		h.size += h.bboxSize()
+	}
+
+	return h, nil
+}
+
+// parseTypeWord reads the byte order marker and type word at the start of
+// src, leaving a flagged SRID to be read by the caller.
+func parseTypeWord(src []byte) (ewkbHeader, error) {
+	if len(src) < 5 {
+		return ewkbHeader{}, classErrorf(ErrInvalidEWKB, "ewkb header too short: %d bytes", len(src))
+	}
+
+	var h ewkbHeader
+	switch src[0] {
+	case 0:
+		h.order = binary.BigEndian
+	case 1:
+		h.order = binary.LittleEndian
+	default:
+		if len(src) >= 9 && src[4] <= 1 {
+			return ewkbHeader{}, classErrorf(ErrInvalidEWKB, "invalid ewkb byte order marker %d; "+
+				"the geometry looks prefixed with a 4-byte SRID, as MySQL stores it, which is not supported", src[0])
+		}
+		return ewkbHeader{}, classErrorf(ErrInvalidEWKB, "invalid ewkb byte order marker %d", src[0])
+	}
+
+	typ := h.order.Uint32(src[1:])
+	h.typ = typ &^ ewkbFlagsMask
+	h.hasZ = typ&ewkbZFlag != 0
+	h.hasM = typ&ewkbMFlag != 0
+	h.hasSRID = typ&ewkbSRIDFlag != 0
+	h.hasBBox = typ&ewkbBBoxFlag != 0
+	h.size = 5
+
+	if dims := h.typ / isoDimsBase; dims > 0 {
+		h.iso = true
+		h.hasZ = h.hasZ || dims&1 != 0
+		h.hasM = h.hasM || dims&2 != 0
+		h.typ %= isoDimsBase
+	}
+
+	return h, nil
+}
+
+// setSRID reads the SRID following the type word from src.
+func (h *ewkbHeader) setSRID(src []byte) {
+	h.srid = int(int32(h.order.Uint32(src)))
+	h.size = 9
+}
+
+// dims returns the number of ordinates of each coordinate.
+func (h ewkbHeader) dims() int {
+	dims := 2
+	if h.hasZ {
+		dims++
+	}
+	if h.hasM {
+		dims++
+	}
+
+	return dims
+}
+
+// bboxSize returns the number of bytes of an embedded bounding box, the
+// minimum and maximum of every ordinate.
+func (h ewkbHeader) bboxSize() int {
+	return 2 * 8 * h.dims()
+}
+
+// hasMembers reports whether the geometry is a multi-geometry or collection,
+// whose members carry headers of their own.
+func (h ewkbHeader) hasMembers() bool {
+	switch h.typ {
+	case multiPointType, multiLineStringType, multiPolygonType, geometryCollectionType:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkSupported reports a descriptive error for geometry types orb can't
+// represent.
+func (h ewkbHeader) checkSupported() error {
+	if surface, ok := surfaceTypeHints[h.typ]; ok {
+		return classErrorf(ErrUnsupportedGeometry, "unsupported surface type %s; %s", surface.name, surface.hint)
+	}
+
+	if name, ok := curveTypeNames[h.typ]; ok {
+		return classErrorf(ErrCurvedGeometry, "unsupported curved type %s; linearize it with ST_CurveToLine(geom)", name)
+	}
+
+	if h.iso && (h.hasZ || h.hasM) {
+		return classErrorf(ErrUnsupportedGeometry, "unsupported iso wkb type code with %d dimensions; "+
+			"convert it with ST_Force2D or send PostGIS EWKB", h.dims())
+	}
+
+	return nil
+}
+
+// checkStrict2D reports an error if the header flags Z or M coordinates.
+func (h ewkbHeader) checkStrict2D() error {
+	if h.hasZ || h.hasM {
+		return classErrorf(ErrNot2D, "geometry has %d dimensions, want 2", h.dims())
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package pgxorb
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// ToHexEWKB returns the hex encoded EWKB of geom with srid, exactly as the
+// text format codec of a default registration sends it. An SRID of 0 yields
+// plain WKB.
+func ToHexEWKB(geom orb.Geometry, srid int) (string, error) {
+	return marshalHex(newConfig(WithSRID(srid)), geom)
+}
+
+// FromHexEWKB decodes a hex encoded EWKB string, such as the text output of
+// a PostGIS geometry, into the geometry and its SRID.
+func FromHexEWKB(s string) (orb.Geometry, int, error) {
+	src, err := decodeHex([]byte(s))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return unmarshalGeometry(src)
+}
+
+// marshalHex encodes value as hex EWKB using cfg. It returns an empty string
+// when value must be sent as NULL.
+func marshalHex(cfg *config, value any) (string, error) {
+	ewkbBuf, err := marshalGeometry(cfg, value)
+	if err != nil || ewkbBuf == nil {
+		return "", err
+	}
+
+	if cfg.upperHex {
+		return strings.ToUpper(hex.EncodeToString(ewkbBuf)), nil
+	}
+
+	return hex.EncodeToString(ewkbBuf), nil
+}
+
+func decodeHex(src []byte) ([]byte, error) {
+	return hex.DecodeString(string(src))
+}
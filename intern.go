@@ -0,0 +1,68 @@
+package pgxorb
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/paulmach/orb"
+)
+
+// An internCache is a bounded LRU cache of decoded geometries keyed by their
+// EWKB. It is shared by the connections of a registration.
+type internCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// order holds the *internEntry values, most recently used first.
+	order *list.List
+}
+
+type internEntry struct {
+	key  string
+	geom orb.Geometry
+	srid int
+}
+
+func newInternCache(size int) *internCache {
+	return &internCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// get returns the geometry decoded from src, if cached.
+func (c *internCache) get(src []byte) (orb.Geometry, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The conversion doesn't allocate for a map lookup.
+	elem, ok := c.entries[string(src)]
+	if !ok {
+		return nil, 0, false
+	}
+	c.order.MoveToFront(elem)
+
+	entry := elem.Value.(*internEntry)
+	return entry.geom, entry.srid, true
+}
+
+// put caches geom decoded from src, evicting the least recently used entry
+// when the cache is full. src is copied, as pgx reuses its buffers.
+func (c *internCache) put(src []byte, geom orb.Geometry, srid int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[string(src)]; ok {
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*internEntry).key)
+	}
+
+	key := string(src)
+	c.entries[key] = c.order.PushFront(&internEntry{key: key, geom: geom, srid: srid})
+}
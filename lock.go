@@ -0,0 +1,47 @@
+package pgxorb
+
+import (
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// A connLock serializes the registrations on a single connection.
+type connLock struct {
+	sync.Mutex
+	// refs is the number of registrations holding or waiting for the lock.
+	refs int
+}
+
+// connLocks holds the locks of the connections being registered. Entries
+// are removed once no registration references them, so closed connections
+// aren't retained.
+var connLocks = struct {
+	sync.Mutex
+	m map[*pgx.Conn]*connLock
+}{m: make(map[*pgx.Conn]*connLock)}
+
+// lockConn blocks until no other registration runs on conn and returns the
+// function releasing it.
+func lockConn(conn *pgx.Conn) (unlock func()) {
+	connLocks.Lock()
+	l, ok := connLocks.m[conn]
+	if !ok {
+		l = new(connLock)
+		connLocks.m[conn] = l
+	}
+	l.refs++
+	connLocks.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		connLocks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(connLocks.m, conn)
+		}
+		connLocks.Unlock()
+	}
+}
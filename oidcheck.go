@@ -0,0 +1,31 @@
+package pgxorb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// knownOIDs maps the databases registered with [WithOIDChangeCheck], as
+// described by describeConn, to the geometry OID first registered on them.
+var knownOIDs sync.Map
+
+// checkOIDChange fails with [ErrOIDChanged] if the geometry OID registered on
+// conn differs from the one first registered on its database in this
+// process, which it records otherwise.
+func checkOIDChange(conn *pgx.Conn) error {
+	t, ok := conn.TypeMap().TypeForName("geometry")
+	if !ok {
+		return nil
+	}
+
+	key := describeConn(conn)
+	prev, loaded := knownOIDs.LoadOrStore(key, t.OID)
+	if loaded && prev.(uint32) != t.OID {
+		return fmt.Errorf("%w: geometry oid %d of %s differs from oid %d registered before",
+			ErrOIDChanged, t.OID, key, prev)
+	}
+
+	return nil
+}
@@ -2,10 +2,582 @@ package pgxorb
 
 import (
 	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync/atomic"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/ewkb"
+	"github.com/paulmach/orb/simplify"
 )
 
-func Register(ctx context.Context, conn *pgx.Conn) error {
-	return registerGeom(ctx, conn)
+// An Option configures the codecs registered by [Register] or a [Registrar].
+type Option func(*config)
+
+// A PlanOp is the kind of plan a [WithPlanHook] hook is notified of.
+type PlanOp int
+
+// Kinds of codec plans.
+const (
+	// PlanEncode is a plan encoding a query parameter.
+	PlanEncode PlanOp = iota
+	// PlanScan is a plan scanning a result column.
+	PlanScan
+)
+
+// String returns the name of the plan kind.
+func (op PlanOp) String() string {
+	switch op {
+	case PlanEncode:
+		return "encode"
+	case PlanScan:
+		return "scan"
+	default:
+		return fmt.Sprintf("PlanOp(%d)", int(op))
+	}
+}
+
+// config holds the settings shared by the codecs and plans of a single
+// registration.
+type config struct {
+	srid          int
+	geographySRID int
+	byteOrder     binary.ByteOrder
+	orientation   orb.Orientation
+	factory       func(orb.Geometry) any
+	simplifier    orb.Simplifier
+	oidQuery      string
+	typeOIDs      map[string]uint32
+	poolerCheck   bool
+	selfTest      bool
+	oidCheck      bool
+	force2D       bool
+	planHook      func(PlanOp, int16)
+	logger        *slog.Logger
+	domains       bool
+	converter     ScanConverter
+	versionCheck  bool
+	minVersion    string
+	intern        *internCache
+	maxDepth      int
+	gridSize      float64
+	scanIfaces    []reflect.Type
+	allowedSRIDs  map[int]struct{}
+
+	collapseSingletons  bool
+	singletonMemberScan bool
+	nonFiniteAsNull     bool
+	emptyAsNull         bool
+	strict2D            bool
+	lenientScan         bool
+	upperHex            bool
+	copyOnDecode        bool
+	swapAxes            bool
+	swapAxesOnDecode    bool
+	skipNilMembers      bool
+	removeRepeated      bool
+	removeCollinear     bool
+}
+
+func newConfig(opts ...Option) *config {
+	cfg := &config{
+		srid:          ewkb.DefaultSRID,
+		geographySRID: defaultGeographySRID,
+		byteOrder:     ewkb.DefaultByteOrder,
+		oidQuery:      defaultOIDQuery,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// debug logs msg with args at debug level to the logger of c, if any.
+func (c *config) debug(ctx context.Context, msg string, args ...any) {
+	if c.logger != nil {
+		c.logger.DebugContext(ctx, msg, args...)
+	}
+}
+
+// WithSRID sets the SRID written into encoded geometries. It defaults to
+// [github.com/paulmach/orb/encoding/ewkb.DefaultSRID]; an SRID of 0 omits it
+// and sends plain WKB. Geographies are encoded with the SRID set by
+// [WithGeographySRID] instead.
+func WithSRID(srid int) Option {
+	return func(c *config) {
+		c.srid = srid
+	}
+}
+
+// WithGeographySRID sets the SRID written into encoded geographies, which
+// must be a lon/lat coordinate system. It defaults to 4326 (WGS 84),
+// independently of [WithSRID], so a projected SRID configured for geometries
+// doesn't reach geography columns.
+func WithGeographySRID(srid int) Option {
+	return func(c *config) {
+		c.geographySRID = srid
+	}
+}
+
+// WithByteOrder sets the byte order used to encode geometries. It defaults to
+// [github.com/paulmach/orb/encoding/ewkb.DefaultByteOrder].
+func WithByteOrder(order binary.ByteOrder) Option {
+	return func(c *config) {
+		c.byteOrder = order
+	}
+}
+
+// WithPolygonOrientation canonicalizes the winding order of encoded polygons:
+// exterior rings are wound in orientation o and interior rings the opposite
+// way. Use [github.com/paulmach/orb.CCW] for the RFC 7946 (GeoJSON) winding.
+// By default rings are sent as given.
+func WithPolygonOrientation(o orb.Orientation) Option {
+	return func(c *config) {
+		c.orientation = o
+	}
+}
+
+// WithGeometryFactory converts every decoded geometry with fn before it is
+// returned or assigned to a scan target, which lets callers decode into their
+// own geometry model. Scan targets must then be pointers to a type the value
+// returned by fn is assignable to.
+func WithGeometryFactory(fn func(orb.Geometry) any) Option {
+	return func(c *config) {
+		c.factory = fn
+	}
+}
+
+// A ScanConverter stores decoded geometries into scan targets outside orb's
+// model, such as the types of another geometry library.
+type ScanConverter interface {
+	// CanScan reports whether targets of the pointer type t are handled by
+	// the converter. It is called once per scan plan, never for a nil
+	// target.
+	CanScan(t reflect.Type) bool
+	// ScanGeometry stores geom, decoded with the given SRID, into target, a
+	// non-nil pointer of a type CanScan accepted.
+	ScanGeometry(geom orb.Geometry, srid int, target any) error
+}
+
+// WithScanConverter hands decoded geometries to c for scan targets it
+// accepts, e.g. to scan into the types of another geometry library. Other
+// targets, including the orb types, are scanned as usual.
+func WithScanConverter(c ScanConverter) Option {
+	return func(cfg *config) {
+		cfg.converter = c
+	}
+}
+
+// WithAllowedSRIDs rejects decoded geometries whose SRID isn't one of srids
+// with an error wrapping [ErrSRIDNotAllowed] and naming the SRID, keeping
+// data in unexpected coordinate systems out. Geometries without an SRID have
+// SRID 0, which must be listed to accept them. The option may be given
+// several times to allow more SRIDs.
+func WithAllowedSRIDs(srids ...int) Option {
+	return func(c *config) {
+		if c.allowedSRIDs == nil {
+			c.allowedSRIDs = make(map[int]struct{}, len(srids))
+		}
+		for _, srid := range srids {
+			c.allowedSRIDs[srid] = struct{}{}
+		}
+	}
+}
+
+// WithCollapseSingletons decodes multi-geometries holding a single member as
+// that member, e.g. a MULTIPOLYGON with one polygon as an [orb.Polygon].
+func WithCollapseSingletons() Option {
+	return func(c *config) {
+		c.collapseSingletons = true
+	}
+}
+
+// WithSingletonMemberScan scans multi-geometries holding a single member into
+// targets of the member's type, e.g. a one-point MULTIPOINT into an
+// [orb.Point], as stored by tools writing every point as a multipoint. Unlike
+// [WithCollapseSingletons], targets accepting the multi-geometry, such as an
+// [orb.MultiPoint] or [orb.Geometry], still receive it as is.
+func WithSingletonMemberScan() Option {
+	return func(c *config) {
+		c.singletonMemberScan = true
+	}
+}
+
+// WithSkipNilMembers leaves out the nil members of encoded collections,
+// including nested ones, which otherwise fail the encode with an error
+// naming the member.
+func WithSkipNilMembers() Option {
+	return func(c *config) {
+		c.skipNilMembers = true
+	}
+}
+
+// WithNonFiniteAsNull encodes geometries with a NaN or infinite coordinate as
+// NULL instead of sending them, so a bad value doesn't fail a whole batch.
+func WithNonFiniteAsNull() Option {
+	return func(c *config) {
+		c.nonFiniteAsNull = true
+	}
+}
+
+// WithEmptyAsNull encodes empty geometries, such as an empty polygon or a
+// collection of empty members, as NULL instead of as POINT EMPTY and the like,
+// so absent and empty values are stored alike. Nil geometries are always sent
+// as NULL.
+func WithEmptyAsNull() Option {
+	return func(c *config) {
+		c.emptyAsNull = true
+	}
+}
+
+// WithStrict2D rejects decoded geometries whose EWKB header flags Z or M
+// coordinates, catching 3D or measured data reaching a column expected to
+// hold plain 2D geometries. The errors wrap [ErrNot2D].
+func WithStrict2D() Option {
+	return func(c *config) {
+		c.strict2D = true
+	}
+}
+
+// WithMaxDepth rejects decoded geometry collections nesting geometries
+// deeper than depth levels, a collection of points being two levels deep, so
+// hostile input can't exhaust the stack. Collections are walked once more
+// before being decoded to check their depth. Geometries nested deeper than
+// 256 levels are always rejected, by the scan plans and the decoding helpers
+// alike; a depth of 0, the default, keeps that limit.
+func WithMaxDepth(depth int) Option {
+	return func(c *config) {
+		c.maxDepth = depth
+	}
+}
+
+// WithLenientScan also accepts scan targets pointing to an interface any
+// geometry satisfies, such as *any, and stores the decoded geometry in them
+// as its concrete orb type.
+func WithLenientScan() Option {
+	return func(c *config) {
+		c.lenientScan = true
+	}
+}
+
+// WithScanInterface also accepts scan targets pointing to the interface type
+// t, such as a model interface of the caller that orb geometries satisfy,
+// given as reflect.TypeFor[Shape](). Decoded geometries not implementing t
+// fail to scan with [ErrTypeMismatch]. Types other than interfaces are
+// ignored. The option may be given several times.
+func WithScanInterface(t reflect.Type) Option {
+	return func(c *config) {
+		if t != nil && t.Kind() == reflect.Interface {
+			c.scanIfaces = append(c.scanIfaces, t)
+		}
+	}
+}
+
+// WithUpperHex encodes geometries in text format as uppercase hex, as
+// returned by ST_AsHEXEWKB, instead of the lowercase hex PostGIS outputs for
+// geometry columns. The server accepts either case.
+func WithUpperHex() Option {
+	return func(c *config) {
+		c.upperHex = true
+	}
+}
+
+// WithSimplify simplifies decoded geometries with the Douglas-Peucker
+// algorithm, dropping vertices closer than tolerance to the simplified line.
+// The tolerance is in the units of the coordinates, e.g. degrees for SRID
+// 4326. It suits rendering, where the full resolution isn't needed.
+func WithSimplify(tolerance float64) Option {
+	return func(c *config) {
+		c.simplifier = simplify.DouglasPeucker(tolerance)
+	}
+}
+
+// WithRemoveRepeatedPoints drops the consecutive duplicate points of the
+// lines and rings of decoded geometries, as ST_RemoveRepeatedPoints does
+// without a tolerance. Unlike [WithSimplify], the shape is left exactly as it
+// was.
+func WithRemoveRepeatedPoints() Option {
+	return func(c *config) {
+		c.removeRepeated = true
+	}
+}
+
+// WithRemoveCollinearPoints is [WithRemoveRepeatedPoints] also dropping the
+// points lying exactly on the straight segment between their neighbours,
+// such as the middle of LINESTRING(0 0,1 1,2 2).
+func WithRemoveCollinearPoints() Option {
+	return func(c *config) {
+		c.removeCollinear = true
+	}
+}
+
+// WithInternCache shares the decoded geometry among identical values, e.g.
+// boundaries repeated across a result set, keeping the size most recently
+// decoded ones in an LRU cache keyed by their EWKB. The cache is shared by all
+// connections of a registration. Interned geometries are shared between scan
+// targets and must not be modified, unless [WithCopyOnDecode] is given. A size
+// of 0 or less disables the cache.
+func WithInternCache(size int) Option {
+	return func(c *config) {
+		c.intern = nil
+		if size > 0 {
+			c.intern = newInternCache(size)
+		}
+	}
+}
+
+// WithCopyOnDecode deep-copies every decoded geometry shared with others,
+// such as the ones served by [WithInternCache], before it is returned or
+// assigned to a scan target, so callers may modify it freely. Geometries that
+// aren't shared are decoded afresh and not copied again.
+func WithCopyOnDecode() Option {
+	return func(c *config) {
+		c.copyOnDecode = true
+	}
+}
+
+// WithSnapToGrid snaps every coordinate of encoded geometries to the nearest
+// multiple of size, so geometries equal within it are sent as identical
+// EWKB, e.g. for deduplication or cache keys, as ST_SnapToGrid does on the
+// server. It is applied after [WithSwapAxes] and before
+// [WithPolygonOrientation]. A size of 0, the default, leaves coordinates as
+// they are.
+func WithSnapToGrid(size float64) Option {
+	return func(c *config) {
+		c.gridSize = size
+	}
+}
+
+// WithSwapAxes exchanges the X and Y of every coordinate of encoded
+// geometries, so parameters holding lat/lon coordinates are stored in the
+// lon/lat order of PostGIS. It is applied before [WithPolygonOrientation].
+func WithSwapAxes() Option {
+	return func(c *config) {
+		c.swapAxes = true
+	}
+}
+
+// WithSwapAxesOnDecode exchanges the X and Y of every coordinate of decoded
+// geometries, so lon/lat columns scan into lat/lon coordinates. It undoes
+// [WithSwapAxes].
+func WithSwapAxesOnDecode() Option {
+	return func(c *config) {
+		c.swapAxesOnDecode = true
+	}
+}
+
+// WithOIDQuery replaces the query resolving the OIDs of the geometry and
+// geography types, which by default resolves them all in a single round trip,
+// also when the schema of the postgis extension isn't on the search path.
+// It is run once per type, the spheroid type included, whose codec is
+// registered only if the query finds it. The type name is its only argument,
+// and it must return the OID, or NULL if the type doesn't exist, as a single
+// column, e.g.
+//
+//	select oid from pg_type where typname = $1 and typnamespace = 'gis'::regnamespace
+func WithOIDQuery(sql string) Option {
+	return func(c *config) {
+		c.oidQuery = sql
+	}
+}
+
+// WithTypeOID presets the OID of the type named name, one of "geometry",
+// "geography", their array types "_geometry" and "_geography", "box2d" or
+// "spheroid", so registration uses it without querying the server. When all
+// the others are preset, the spheroid codec is registered only if its OID is
+// preset too.
+func WithTypeOID(name string, oid uint32) Option {
+	return func(c *config) {
+		if c.typeOIDs == nil {
+			c.typeOIDs = make(map[string]uint32)
+		}
+		c.typeOIDs[name] = oid
+	}
+}
+
+// WithForce2D encodes [Flattener] values by flattening them to 2D, deliberately
+// dropping their Z and M ordinates. Without it they are rejected.
+func WithForce2D() Option {
+	return func(c *config) {
+		c.force2D = true
+	}
+}
+
+// WithPlanHook calls fn whenever pgx plans to encode or scan a geometry, with
+// the wire format of the plan, so metrics can reveal connections falling back
+// to the slower text format. pgx plans scans once per query and column, while
+// encode plans are memoized per connection and value type. fn may be called
+// concurrently from several connections.
+func WithPlanHook(fn func(op PlanOp, format int16)) Option {
+	return func(c *config) {
+		c.planHook = fn
+	}
+}
+
+// WithLogger logs the steps of registration, such as the type OIDs resolved
+// and the codecs registered, to logger at debug level. Nothing is logged by
+// default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithDomains also registers the codecs under every domain over geometry or
+// geography, such as one created by CREATE DOMAIN geom4326 AS geometry, as
+// PostgreSQL reports columns of a domain type with the OID of the domain.
+// Domains are looked up once at registration, with one extra query.
+func WithDomains() Option {
+	return func(c *config) {
+		c.domains = true
+	}
+}
+
+// WithMinPostGISVersion makes registration query the version of the PostGIS
+// library, such as 3.4.2, and fail with [ErrUnsupportedPostGIS] when it is
+// older than version. An empty version accepts any. The version found is
+// reported by [Registrar.PostGISVersion]. The check costs one query.
+func WithMinPostGISVersion(version string) Option {
+	return func(c *config) {
+		c.versionCheck = true
+		c.minVersion = version
+	}
+}
+
+// WithDetectPooler makes registration fail with [ErrProxiedConn] when the
+// connection appears to go through a transaction pooler such as PgBouncer,
+// where the server connection behind it changes between transactions. The
+// check is best-effort and costs one query.
+func WithDetectPooler() Option {
+	return func(c *config) {
+		c.poolerCheck = true
+	}
+}
+
+// WithSelfTest makes registration round-trip a point through the server and
+// fail with [ErrSelfTestFailed] unless it comes back unchanged, so codecs
+// registered under wrong OIDs fail at startup rather than on the first
+// query. The check costs one query.
+func WithSelfTest() Option {
+	return func(c *config) {
+		c.selfTest = true
+	}
+}
+
+// WithOIDChangeCheck makes registration fail with [ErrOIDChanged] when the
+// geometry OID of a database differs from the one first registered on it in
+// this process, as after the postgis extension was dropped and recreated
+// while the application kept running. Codecs cached elsewhere under the old
+// OID, such as in prepared statements, would otherwise misbehave silently.
+func WithOIDChangeCheck() Option {
+	return func(c *config) {
+		c.oidCheck = true
+	}
+}
+
+// A Registrar registers the codecs with a configuration fixed at
+// construction, so one policy can be shared by a pool's AfterConnect and
+// standalone connections. It is safe for concurrent use.
+type Registrar struct {
+	cfg *config
+	// version is the PostGIS version found by the latest registration
+	// with WithMinPostGISVersion.
+	version atomic.Pointer[string]
+}
+
+// NewRegistrar returns a Registrar configured by opts.
+func NewRegistrar(opts ...Option) *Registrar {
+	return &Registrar{cfg: newConfig(opts...)}
+}
+
+// Register registers the PostGIS geometry, geography and box2d codecs on
+// conn, and the spheroid codec if the type exists. Its signature fits the
+// AfterConnect hook of a pgxpool config. Concurrent registrations on the same
+// conn are run one at a time.
+func (r *Registrar) Register(ctx context.Context, conn *pgx.Conn) error {
+	unlock := lockConn(conn)
+	defer unlock()
+
+	if r.cfg.poolerCheck {
+		if err := checkPooler(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	if r.cfg.versionCheck {
+		version, err := checkPostGISVersion(ctx, conn, r.cfg.minVersion)
+		if err != nil {
+			return err
+		}
+		r.version.Store(&version)
+	}
+
+	if err := registerCodecs(ctx, conn, r.cfg); err != nil {
+		return err
+	}
+
+	if r.cfg.oidCheck {
+		if err := checkOIDChange(conn); err != nil {
+			return err
+		}
+	}
+
+	if r.cfg.selfTest {
+		return selfTest(ctx, conn, r.cfg)
+	}
+
+	return nil
+}
+
+// registerCodecs registers the codecs configured by cfg on conn, resolving the
+// OIDs of their types in a single query.
+func registerCodecs(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	cfg, err := prefetchTypeOIDs(ctx, conn, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := registerGeom(ctx, conn, cfg); err != nil {
+		return err
+	}
+
+	if err := registerGeography(ctx, conn, cfg); err != nil {
+		return err
+	}
+
+	if err := registerBox2D(ctx, conn, cfg); err != nil {
+		return err
+	}
+
+	if err := registerSpheroid(ctx, conn, cfg); err != nil {
+		return err
+	}
+
+	if cfg.domains {
+		return registerDomains(ctx, conn, cfg)
+	}
+
+	return nil
+}
+
+// PostGISVersion returns the version of the PostGIS library found by the
+// latest registration, or an empty string without [WithMinPostGISVersion] or
+// before the first registration.
+func (r *Registrar) PostGISVersion() string {
+	if version := r.version.Load(); version != nil {
+		return *version
+	}
+
+	return ""
+}
+
+// Register registers the PostGIS geometry, geography and box2d codecs on
+// conn, and the spheroid codec if the type exists, configured by opts.
+func Register(ctx context.Context, conn *pgx.Conn, opts ...Option) error {
+	return NewRegistrar(opts...).Register(ctx, conn)
 }
@@ -0,0 +1,35 @@
+package pgxorb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// checkPooler reports [ErrProxiedConn] when the backend serving conn isn't
+// the one announced at startup. Poolers like PgBouncer hand out their own
+// backend key data and may run each transaction on a different server
+// connection, so the PIDs differ.
+func checkPooler(ctx context.Context, conn *pgx.Conn) error {
+	var pid uint32
+	if err := conn.QueryRow(ctx, "select pg_backend_pid()").Scan(&pid); err != nil {
+		return fmt.Errorf("get backend pid failed on %s: %w", describeConn(conn), err)
+	}
+
+	if startup := conn.PgConn().PID(); pid != startup {
+		return fmt.Errorf("%w: backend pid %d differs from pid %d announced at startup on %s",
+			ErrProxiedConn, pid, startup, describeConn(conn))
+	}
+
+	return nil
+}
+
+// BeforeAcquire registers the codecs on conn again, so that OIDs which went
+// stale behind a pooler, e.g. after the extension was re-created, are
+// resolved afresh. Its signature fits the BeforeAcquire hook of a pgxpool
+// config; it returns false to have the pool discard conn when registration
+// fails. Combine it with [WithTypeOID] to avoid the queries on every acquire.
+func (r *Registrar) BeforeAcquire(ctx context.Context, conn *pgx.Conn) bool {
+	return r.Register(ctx, conn) == nil
+}
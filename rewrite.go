@@ -0,0 +1,260 @@
+package pgxorb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/paulmach/orb"
+)
+
+// GeometryCasts is a [github.com/jackc/pgx/v5.QueryRewriter] casting the
+// placeholders of geometry arguments, so queries needn't spell out the casts
+// PostgreSQL requires to resolve e.g. the overloads of ST_AsText. Passed as
+// the first argument of a query method, it appends ::geometry to every
+// placeholder, such as $1, of an orb geometry or a wrapper such as
+// [GeometryWithSRID], and ::geography to those of a [Geography]:
+//
+//	conn.QueryRow(ctx, "select ST_AsText($1)", pgxorb.GeometryCasts{}, orb.Point{1, 2})
+//
+// Placeholders of other arguments, placeholders already followed by a cast,
+// and text inside string literals, quoted identifiers and comments are left
+// as is.
+type GeometryCasts struct{}
+
+var _ pgx.QueryRewriter = GeometryCasts{}
+
+// RewriteQuery implements [github.com/jackc/pgx/v5.QueryRewriter].
+func (GeometryCasts) RewriteQuery(
+	_ context.Context,
+	_ *pgx.Conn,
+	sql string,
+	args []any,
+) (newSQL string, newArgs []any, err error) {
+	casts := make([]string, len(args))
+	cast := false
+	for i, arg := range args {
+		casts[i] = castFor(arg)
+		cast = cast || casts[i] != ""
+	}
+	if !cast {
+		return sql, args, nil
+	}
+
+	return castPlaceholders(sql, casts), args, nil
+}
+
+// GeomFromWKB is a [github.com/jackc/pgx/v5.QueryRewriter] sending geometry
+// arguments as plain WKB in a bytea and wrapping their placeholders in
+// ST_GeomFromWKB, which sets their SRID on the server, for compatibility with
+// any PostGIS version as no EWKB is sent. Passed as the first argument of a
+// query method, it rewrites the placeholders of orb geometries and wrappers
+// such as [GeometryWithSRID]:
+//
+//	conn.Exec(ctx, "insert into places (geom) values ($1)", pgxorb.GeomFromWKB{SRID: 4326}, orb.Point{1, 2})
+//
+// runs insert into places (geom) values (ST_GeomFromWKB($1, 4326)). The
+// placeholders of a [Geography] are cast to geography as well.
+type GeomFromWKB struct {
+	// SRID is set on geometries without an SRID of their own, such as a
+	// plain orb geometry. It doesn't apply to a [Geography], which is set
+	// SRID 4326 as by the geography codec.
+	SRID int
+}
+
+var _ pgx.QueryRewriter = GeomFromWKB{}
+
+// RewriteQuery implements [github.com/jackc/pgx/v5.QueryRewriter].
+func (r GeomFromWKB) RewriteQuery(
+	_ context.Context,
+	_ *pgx.Conn,
+	sql string,
+	args []any,
+) (newSQL string, newArgs []any, err error) {
+	cfg := newConfig(WithSRID(r.SRID))
+	geographyCfg := *cfg
+	geographyCfg.srid = cfg.geographySRID
+
+	wrappers := make([]string, len(args))
+	newArgs = slices.Clone(args)
+	for i, arg := range args {
+		cast := castFor(arg)
+		if cast == "" {
+			continue
+		}
+
+		argCfg := cfg
+		if cast == "::geography" {
+			argCfg = &geographyCfg
+		}
+
+		geom, srid, err := resolveGeometry(argCfg, arg)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode argument %d: %w", i+1, err)
+		}
+
+		// Without an SRID, the EWKB is plain WKB.
+		newArgs[i], err = appendEWKB(nil, geom, 0, cfg.byteOrder)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode argument %d: %w", i+1, err)
+		}
+
+		wrappers[i] = ", " + strconv.Itoa(srid) + ")"
+		if cast == "::geography" {
+			wrappers[i] += cast
+		}
+	}
+
+	newSQL = rewritePlaceholders(sql, len(args), func(n int, placeholder, _ string) string {
+		if wrappers[n-1] == "" {
+			return placeholder
+		}
+		return "ST_GeomFromWKB(" + placeholder + wrappers[n-1]
+	})
+
+	return newSQL, newArgs, nil
+}
+
+// castFor returns the cast for the placeholder of arg, or an empty string if
+// arg isn't a geometry.
+func castFor(arg any) string {
+	switch derefValue(arg).(type) {
+	case Geography:
+		return "::geography"
+	case orb.Geometry, geometryWrapper:
+		return "::geometry"
+	default:
+		return ""
+	}
+}
+
+// castPlaceholders appends casts[n-1] to every placeholder $n of sql not
+// followed by a cast already.
+func castPlaceholders(sql string, casts []string) string {
+	return rewritePlaceholders(sql, len(casts), func(n int, placeholder, rest string) string {
+		if strings.HasPrefix(rest, "::") {
+			return placeholder
+		}
+		return placeholder + casts[n-1]
+	})
+}
+
+// rewritePlaceholders replaces every placeholder $n of sql, for n from 1 to
+// count, by the result of replace, given the placeholder and the sql
+// following it. String literals, quoted identifiers, comments and
+// dollar-quoted strings are copied as is.
+func rewritePlaceholders(sql string, count int, replace func(n int, placeholder, rest string) string) string {
+	var b strings.Builder
+	b.Grow(len(sql) + 16*count)
+
+	for i := 0; i < len(sql); {
+		end := i + 1
+		switch c := sql[i]; {
+		case c == '\'':
+			escapes := i > 0 && (sql[i-1] == 'e' || sql[i-1] == 'E') && (i < 2 || !isIdentByte(sql[i-2]))
+			end = quotedEnd(sql, i, '\'', escapes)
+		case c == '"':
+			end = quotedEnd(sql, i, '"', false)
+		case strings.HasPrefix(sql[i:], "--"):
+			end = len(sql)
+			if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+				end = i + n + 1
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = blockCommentEnd(sql, i)
+		case c == '$' && (i == 0 || !isIdentByte(sql[i-1])):
+			if n := digitsEnd(sql, i+1); n > i+1 {
+				if idx, err := strconv.Atoi(sql[i+1 : n]); err == nil && idx >= 1 && idx <= count {
+					b.WriteString(replace(idx, sql[i:n], sql[n:]))
+				} else {
+					b.WriteString(sql[i:n])
+				}
+				i = n
+				continue
+			}
+			end = dollarQuotedEnd(sql, i)
+		}
+
+		b.WriteString(sql[i:end])
+		i = end
+	}
+
+	return b.String()
+}
+
+// quotedEnd returns the index just past the quoted text starting at i, in
+// which quote is escaped by doubling it and, with escapes, by a backslash.
+func quotedEnd(sql string, i int, quote byte, escapes bool) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch {
+		case escapes && sql[j] == '\\':
+			j++
+		case sql[j] == quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+
+	return len(sql)
+}
+
+// blockCommentEnd returns the index just past the block comment starting at
+// i, which may nest.
+func blockCommentEnd(sql string, i int) int {
+	depth := 0
+	for j := i; j+1 < len(sql); j++ {
+		switch sql[j : j+2] {
+		case "/*":
+			depth++
+			j++
+		case "*/":
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+
+	return len(sql)
+}
+
+// dollarQuotedEnd returns the index just past the dollar-quoted string, such
+// as $tag$...$tag$, starting at i, or i+1 if there is none.
+func dollarQuotedEnd(sql string, i int) int {
+	j := i + 1
+	for j < len(sql) && sql[j] != '$' && isIdentByte(sql[j]) {
+		j++
+	}
+	if j == len(sql) || sql[j] != '$' {
+		return i + 1
+	}
+
+	tag := sql[i : j+1]
+	if n := strings.Index(sql[j+1:], tag); n >= 0 {
+		return j + 1 + n + len(tag)
+	}
+
+	return len(sql)
+}
+
+// digitsEnd returns the index of the first byte of sql from i on that isn't a
+// digit.
+func digitsEnd(sql string, i int) int {
+	for i < len(sql) && '0' <= sql[i] && sql[i] <= '9' {
+		i++
+	}
+
+	return i
+}
+
+// isIdentByte reports whether c may appear in an unquoted identifier.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,140 @@
+package pgxorb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// Iterate decodes the first column of each row of rows as a geometry and
+// calls fn with it, so memory stays bounded by a single geometry no matter
+// how large the result set is. NULL values are passed to fn as nil. Iteration
+// stops at the first error returned by fn, and rows is closed on return.
+func Iterate(rows pgx.Rows, fn func(orb.Geometry) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var geom orb.Geometry
+		if err := scanFirstColumn(rows, &geom); err != nil {
+			return err
+		}
+
+		if err := fn(geom); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// QueryGeometries runs sql with args on conn and returns the first column of
+// every row decoded as a geometry, with nil for NULL values.
+func QueryGeometries(ctx context.Context, conn *pgx.Conn, sql string, args ...any) ([]orb.Geometry, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var geoms []orb.Geometry
+	err = Iterate(rows, func(geom orb.Geometry) error {
+		geoms = append(geoms, geom)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return geoms, nil
+}
+
+// CollectMultiPoint decodes the first column of each row of rows as a point
+// and collects them in order into a multi-point, e.g. to assemble the result
+// of a nearest neighbour query. NULL values are skipped, and a geometry that
+// isn't a point fails with [ErrTypeMismatch]. rows is closed on return.
+func CollectMultiPoint(rows pgx.Rows) (orb.MultiPoint, error) {
+	mp := orb.MultiPoint{}
+	err := Iterate(rows, func(geom orb.Geometry) error {
+		switch g := geom.(type) {
+		case nil:
+			return nil
+		case orb.Point:
+			mp = append(mp, g)
+			return nil
+		default:
+			return fmt.Errorf("%w: got a %s, want a Point", ErrTypeMismatch, g.GeoJSONType())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mp, nil
+}
+
+// ScanFeature builds a GeoJSON feature from the current row of rows, with the
+// column named geomCol decoded as its geometry and the columns named by
+// propCols as its properties, or all other columns when propCols is empty.
+// Property values are decoded as by [github.com/jackc/pgx/v5.Rows.Values]. A
+// NULL geometry yields a feature without geometry.
+func ScanFeature(rows pgx.Rows, geomCol string, propCols ...string) (*geojson.Feature, error) {
+	fields := rows.FieldDescriptions()
+	columns := make(map[string]int, len(fields))
+	for i, field := range fields {
+		columns[field.Name] = i
+	}
+
+	geomIdx, ok := columns[geomCol]
+	if !ok {
+		return nil, fmt.Errorf("column %s not found", geomCol)
+	}
+
+	if len(propCols) == 0 {
+		for _, field := range fields {
+			if field.Name != geomCol {
+				propCols = append(propCols, field.Name)
+			}
+		}
+	}
+
+	var geom orb.Geometry
+	if err := scanColumn(rows, geomIdx, &geom); err != nil {
+		return nil, fmt.Errorf("scan column %s failed: %w", geomCol, err)
+	}
+
+	feature := geojson.NewFeature(geom)
+	for _, name := range propCols {
+		i, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("column %s not found", name)
+		}
+
+		var value any
+		if err := scanColumn(rows, i, &value); err != nil {
+			return nil, fmt.Errorf("scan column %s failed: %w", name, err)
+		}
+		feature.Properties[name] = value
+	}
+
+	return feature, nil
+}
+
+// scanFirstColumn scans the first column of the current row into dst,
+// ignoring any further columns.
+func scanFirstColumn(rows pgx.Rows, dst any) error {
+	fields := rows.FieldDescriptions()
+	if len(fields) == 0 {
+		return errors.New("query returned no columns")
+	}
+
+	return scanColumn(rows, 0, dst)
+}
+
+// scanColumn scans the i-th column of the current row into dst.
+func scanColumn(rows pgx.Rows, i int, dst any) error {
+	field := rows.FieldDescriptions()[i]
+	return rows.Conn().TypeMap().Scan(field.DataTypeOID, field.Format, rows.RawValues()[i], dst)
+}
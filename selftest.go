@@ -0,0 +1,59 @@
+package pgxorb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/paulmach/orb"
+)
+
+// selfTestPoint is the geometry sent and selected back by the self-test.
+var selfTestPoint = orb.Point{1, 2}
+
+// selfTest round-trips a point through the server with the codecs registered
+// on conn and reports [ErrSelfTestFailed] unless it comes back as the encode
+// options of cfg, such as WithSwapAxes, make it, from a column whose OID is
+// registered as geometry. The selected EWKB is compared undecoded by the
+// codec, so decode options don't fail the check. pgx falls back to the codec
+// registered for the Go type of a value with an unknown OID, so the round
+// trip alone doesn't catch codecs registered under the wrong OIDs.
+func selfTest(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	sent, err := appendGeometry(cfg, nil, selfTestPoint)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encode %v: %w", ErrSelfTestFailed, selfTestPoint, err)
+	}
+	want, wantSRID, err := unmarshalGeometry(sent)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encode %v: %w", ErrSelfTestFailed, selfTestPoint, err)
+	}
+
+	rows, err := conn.Query(ctx, "select $1::geometry", pgx.QueryResultFormats{pgx.BinaryFormatCode}, selfTestPoint)
+	if err != nil {
+		return fmt.Errorf("%w: round trip failed on %s: %w", ErrSelfTestFailed, describeConn(conn), err)
+	}
+	src, err := pgx.CollectExactlyOneRow(rows, func(row pgx.CollectableRow) ([]byte, error) {
+		return bytes.Clone(row.RawValues()[0]), nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: round trip failed on %s: %w", ErrSelfTestFailed, describeConn(conn), err)
+	}
+
+	oid := rows.FieldDescriptions()[0].DataTypeOID
+	if typ, ok := conn.TypeMap().TypeForOID(oid); !ok || typ.Name != "geometry" {
+		return fmt.Errorf("%w: geometry oid %d isn't registered on %s", ErrSelfTestFailed, oid, describeConn(conn))
+	}
+
+	got, gotSRID, err := unmarshalGeometry(src)
+	if err != nil {
+		return fmt.Errorf("%w: round trip failed on %s: %w", ErrSelfTestFailed, describeConn(conn), err)
+	}
+
+	if !orb.Equal(got, want) || gotSRID != wantSRID {
+		return fmt.Errorf("%w: sent %v with srid %d, got %v with srid %d on %s",
+			ErrSelfTestFailed, want, wantSRID, got, gotSRID, describeConn(conn))
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package pgxorb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// A Spheroid is a value of the PostGIS spheroid type, the reference
+// ellipsoid taken by functions such as ST_DistanceSpheroid.
+type Spheroid struct {
+	// Name is the name of the spheroid, such as WGS 84.
+	Name string
+	// SemiMajorAxis is the equatorial radius in meters.
+	SemiMajorAxis float64
+	// InverseFlattening is the reciprocal of the flattening, such as
+	// 298.257223563.
+	InverseFlattening float64
+}
+
+// spheroidCodec implements [github.com/jackc/pgx/v5/pgtype.Codec] for the
+// PostGIS spheroid type as a [Spheroid]. spheroid has no binary I/O, so only
+// the text format SPHEROID["name",a,rf] is supported; the server outputs it
+// with parentheses instead of brackets.
+type spheroidCodec struct{}
+
+// A spheroidEncodePlan implements
+// [github.com/jackc/pgx/v5/pgtype.EncodePlan] for [Spheroid] in text format.
+type spheroidEncodePlan struct{}
+
+// A spheroidScanPlan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan] for
+// *[Spheroid] in text format.
+type spheroidScanPlan struct{}
+
+// FormatSupported implements
+// [github.com/jackc/pgx/v5/pgtype.Codec.FormatSupported].
+func (spheroidCodec) FormatSupported(format int16) bool {
+	return format == pgtype.TextFormatCode
+}
+
+// PreferredFormat implements
+// [github.com/jackc/pgx/v5/pgtype.Codec.PreferredFormat].
+func (spheroidCodec) PreferredFormat() int16 {
+	return pgtype.TextFormatCode
+}
+
+// PlanEncode implements [github.com/jackc/pgx/v5/pgtype.Codec.PlanEncode].
+func (spheroidCodec) PlanEncode(m *pgtype.Map, oid uint32, format int16, value any) pgtype.EncodePlan {
+	if format != pgtype.TextFormatCode {
+		return nil
+	}
+
+	switch value.(type) {
+	case Spheroid, *Spheroid:
+		return spheroidEncodePlan{}
+	default:
+		return nil
+	}
+}
+
+// PlanScan implements [github.com/jackc/pgx/v5/pgtype.Codec.PlanScan].
+func (spheroidCodec) PlanScan(m *pgtype.Map, oid uint32, format int16, target any) pgtype.ScanPlan {
+	if format != pgtype.TextFormatCode {
+		return nil
+	}
+
+	if _, ok := target.(*Spheroid); !ok {
+		return nil
+	}
+
+	return spheroidScanPlan{}
+}
+
+// DecodeDatabaseSQLValue implements
+// [github.com/jackc/pgx/v5/pgtype.Codec.DecodeDatabaseSQLValue].
+func (spheroidCodec) DecodeDatabaseSQLValue(m *pgtype.Map, oid uint32, format int16, src []byte) (driver.Value, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	return string(src), nil
+}
+
+// DecodeValue implements [github.com/jackc/pgx/v5/pgtype.Codec.DecodeValue].
+func (spheroidCodec) DecodeValue(m *pgtype.Map, oid uint32, format int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	var s Spheroid
+	if err := (spheroidScanPlan{}).Scan(src, &s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Encode implements [github.com/jackc/pgx/v5/pgtype.EncodePlan.Encode].
+func (spheroidEncodePlan) Encode(value any, buf []byte) (newBuf []byte, err error) {
+	var s Spheroid
+	switch v := value.(type) {
+	case Spheroid:
+		s = v
+	case *Spheroid:
+		if v == nil {
+			return nil, nil
+		}
+		s = *v
+	default:
+		return nil, errors.ErrUnsupported
+	}
+
+	if strings.Contains(s.Name, `"`) {
+		return nil, fmt.Errorf("spheroid name %q must not contain a double quote", s.Name)
+	}
+
+	buf = append(buf, `SPHEROID["`...)
+	buf = append(buf, s.Name...)
+	buf = append(buf, `",`...)
+	buf = strconv.AppendFloat(buf, s.SemiMajorAxis, 'g', -1, 64)
+	buf = append(buf, ',')
+	buf = strconv.AppendFloat(buf, s.InverseFlattening, 'g', -1, 64)
+
+	return append(buf, ']'), nil
+}
+
+// Scan implements [github.com/jackc/pgx/v5/pgtype.ScanPlan.Scan].
+func (spheroidScanPlan) Scan(src []byte, target any) error {
+	dst, ok := target.(*Spheroid)
+	if !ok || dst == nil {
+		return fmt.Errorf("target must be a non-nil *pgxorb.Spheroid, got %T", target)
+	}
+
+	if src == nil {
+		return nil
+	}
+
+	s, err := parseSpheroid(string(src))
+	if err != nil {
+		return fmt.Errorf("invalid spheroid %q: %w", src, err)
+	}
+	*dst = s
+
+	return nil
+}
+
+// parseSpheroid parses the text format of a spheroid, with its values in
+// parentheses or brackets.
+func parseSpheroid(text string) (Spheroid, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(text), "SPHEROID")
+	if !ok || len(body) < 2 {
+		return Spheroid{}, errors.New("missing SPHEROID prefix")
+	}
+
+	switch body[0:1] + body[len(body)-1:] {
+	case "()", "[]":
+		body = body[1 : len(body)-1]
+	default:
+		return Spheroid{}, errors.New("unbalanced parentheses")
+	}
+
+	body, ok = strings.CutPrefix(body, `"`)
+	if !ok {
+		return Spheroid{}, errors.New("missing quoted name")
+	}
+	name, numbers, ok := strings.Cut(body, `",`)
+	if !ok {
+		return Spheroid{}, errors.New("missing quoted name")
+	}
+
+	a, rf, ok := strings.Cut(numbers, ",")
+	if !ok {
+		return Spheroid{}, errors.New("missing inverse flattening")
+	}
+
+	s := Spheroid{Name: name}
+	var err error
+	if s.SemiMajorAxis, err = strconv.ParseFloat(strings.TrimSpace(a), 64); err != nil {
+		return Spheroid{}, err
+	}
+	if s.InverseFlattening, err = strconv.ParseFloat(strings.TrimSpace(rf), 64); err != nil {
+		return Spheroid{}, err
+	}
+
+	return s, nil
+}
+
+// registerSpheroid registers the spheroid codec if the type exists. Unlike
+// the other types, a missing spheroid type doesn't fail registration. Its OID
+// is normally prefetched along with theirs, but a custom OID query resolves it
+// on its own.
+func registerSpheroid(ctx context.Context, conn *pgx.Conn, cfg *config) error {
+	oid, ok := cfg.typeOIDs["spheroid"]
+	if !ok && cfg.oidQuery != defaultOIDQuery {
+		var err error
+		if oid, ok, err = lookupTypeOID(ctx, conn, cfg, "spheroid"); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	conn.TypeMap().RegisterType(&pgtype.Type{
+		Name:  "spheroid",
+		Codec: spheroidCodec{},
+		OID:   oid,
+	})
+	conn.TypeMap().RegisterDefaultPgType(Spheroid{}, "spheroid")
+	cfg.debug(ctx, "registered codec", "type", "spheroid", "oid", oid)
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package pgxorb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// AfterConnect returns a hook that registers the codecs on every new
+// connection, configured by opts. It fits both
+// [github.com/jackc/pgx/v5/stdlib.OptionAfterConnect] and the AfterConnect
+// field of a pgxpool config.
+func AfterConnect(opts ...Option) func(context.Context, *pgx.Conn) error {
+	return NewRegistrar(opts...).Register
+}
+
+// OpenDB opens a [database/sql.DB] on the pgx stdlib driver whose connections
+// have the codecs registered, configured by opts. Geometries can then be
+// passed as query arguments directly; geometry columns are returned as hex
+// EWKB strings, which [FromHexEWKB] decodes.
+func OpenDB(config pgx.ConnConfig, opts ...Option) *sql.DB {
+	return stdlib.OpenDB(config, stdlib.OptionAfterConnect(AfterConnect(opts...)))
+}
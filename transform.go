@@ -0,0 +1,340 @@
+package pgxorb
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/project"
+)
+
+// orientGeometry returns geom with the exterior rings of its polygons wound
+// in orientation o and the interior rings wound the opposite way. Rings are
+// copied before being reversed, geom itself is never modified. Nil slices stay
+// nil, so they are still sent as NULL.
+func orientGeometry(geom orb.Geometry, o orb.Orientation) orb.Geometry {
+	switch g := geom.(type) {
+	case orb.Ring:
+		return orientRing(g, o)
+	case orb.Polygon:
+		return orientPolygon(g, o)
+	case orb.MultiPolygon:
+		if g == nil {
+			return g
+		}
+		mp := make(orb.MultiPolygon, len(g))
+		for i, p := range g {
+			mp[i] = orientPolygon(p, o)
+		}
+		return mp
+	case orb.Collection:
+		if g == nil {
+			return g
+		}
+		c := make(orb.Collection, len(g))
+		for i, member := range g {
+			c[i] = orientGeometry(member, o)
+		}
+		return c
+	default:
+		return geom
+	}
+}
+
+func orientPolygon(p orb.Polygon, o orb.Orientation) orb.Polygon {
+	if p == nil {
+		return nil
+	}
+
+	oriented := make(orb.Polygon, len(p))
+	for i, r := range p {
+		if i == 0 {
+			oriented[i] = orientRing(r, o)
+		} else {
+			oriented[i] = orientRing(r, -o)
+		}
+	}
+
+	return oriented
+}
+
+// orientRing returns r wound in orientation o. Degenerate rings without an
+// orientation are returned as is.
+func orientRing(r orb.Ring, o orb.Orientation) orb.Ring {
+	if r.Orientation() != -o {
+		return r
+	}
+
+	reversed := r.Clone()
+	reversed.Reverse()
+
+	return reversed
+}
+
+// collapseSingleton returns the only member of a multi-geometry holding a
+// single one, and geom otherwise.
+func collapseSingleton(geom orb.Geometry) orb.Geometry {
+	switch g := geom.(type) {
+	case orb.MultiPoint:
+		if len(g) == 1 {
+			return g[0]
+		}
+	case orb.MultiLineString:
+		if len(g) == 1 {
+			return g[0]
+		}
+	case orb.MultiPolygon:
+		if len(g) == 1 {
+			return g[0]
+		}
+	}
+
+	return geom
+}
+
+// removeRepeatedPoints returns geom with the consecutive duplicates of the
+// points of its lines and rings dropped and, with collinear, the points lying
+// on the straight segment between their neighbours. Lines and rings left with
+// fewer than 2 and 4 points respectively are kept as they were. The start of
+// a ring, which also closes it, is always kept.
+func removeRepeatedPoints(geom orb.Geometry, collinear bool) orb.Geometry {
+	switch g := geom.(type) {
+	case orb.LineString:
+		return orb.LineString(cleanPoints(g, collinear, 2))
+	case orb.Ring:
+		return orb.Ring(cleanPoints(g, collinear, 4))
+	case orb.MultiLineString:
+		mls := make(orb.MultiLineString, len(g))
+		for i, ls := range g {
+			mls[i] = orb.LineString(cleanPoints(ls, collinear, 2))
+		}
+		return mls
+	case orb.Polygon:
+		return cleanPolygon(g, collinear)
+	case orb.MultiPolygon:
+		mp := make(orb.MultiPolygon, len(g))
+		for i, p := range g {
+			mp[i] = cleanPolygon(p, collinear)
+		}
+		return mp
+	case orb.Collection:
+		c := make(orb.Collection, len(g))
+		for i, member := range g {
+			c[i] = removeRepeatedPoints(member, collinear)
+		}
+		return c
+	default:
+		return geom
+	}
+}
+
+func cleanPolygon(p orb.Polygon, collinear bool) orb.Polygon {
+	if p == nil {
+		return nil
+	}
+
+	cleaned := make(orb.Polygon, len(p))
+	for i, r := range p {
+		cleaned[i] = orb.Ring(cleanPoints(r, collinear, 4))
+	}
+
+	return cleaned
+}
+
+// cleanPoints returns a copy of points without consecutive duplicates and,
+// with collinear, without points between their neighbours on a straight
+// segment, or points itself if fewer than minPoints would be left.
+func cleanPoints(points []orb.Point, collinear bool, minPoints int) []orb.Point {
+	cleaned := make([]orb.Point, 0, len(points))
+	for _, p := range points {
+		n := len(cleaned)
+		if n > 0 && cleaned[n-1] == p {
+			continue
+		}
+
+		for collinear && n >= 2 && isBetween(cleaned[n-2], cleaned[n-1], p) {
+			cleaned = cleaned[:n-1]
+			n--
+		}
+		cleaned = append(cleaned, p)
+	}
+
+	if len(cleaned) < minPoints {
+		return points
+	}
+
+	return cleaned
+}
+
+// isBetween reports whether b lies on the straight segment from a to c, so
+// dropping it leaves the shape of a line through a, b and c unchanged. A b
+// where the line turns back, forming a spike, isn't between.
+func isBetween(a, b, c orb.Point) bool {
+	cross := (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+	dot := (b[0]-a[0])*(c[0]-b[0]) + (b[1]-a[1])*(c[1]-b[1])
+
+	return cross == 0 && dot > 0
+}
+
+// SplitCollection classifies the members of gc by type, e.g. to handle the
+// points, lines and areas of a GEOMETRYCOLLECTION separately. Members of
+// multi-geometries and nested collections are classified in turn; rings and
+// bounds count as polygons, and nil members are left out. Members keep their
+// order within each slice.
+func SplitCollection(gc orb.Collection) (points []orb.Point, lines []orb.LineString, polys []orb.Polygon) {
+	var split func(orb.Geometry)
+	split = func(geom orb.Geometry) {
+		switch g := normalizeGeometry(geom).(type) {
+		case orb.Point:
+			points = append(points, g)
+		case orb.MultiPoint:
+			points = append(points, g...)
+		case orb.LineString:
+			lines = append(lines, g)
+		case orb.MultiLineString:
+			lines = append(lines, g...)
+		case orb.Polygon:
+			polys = append(polys, g)
+		case orb.MultiPolygon:
+			polys = append(polys, g...)
+		case orb.Collection:
+			for _, member := range g {
+				split(member)
+			}
+		}
+	}
+	split(gc)
+
+	return points, lines, polys
+}
+
+// eachPoint calls fn with every coordinate of geom until fn returns false,
+// and reports whether all calls returned true.
+func eachPoint(geom orb.Geometry, fn func(orb.Point) bool) bool {
+	switch g := geom.(type) {
+	case orb.Point:
+		return fn(g)
+	case orb.MultiPoint:
+		return eachPointIn(g, fn)
+	case orb.LineString:
+		return eachPointIn(g, fn)
+	case orb.Ring:
+		return eachPointIn(g, fn)
+	case orb.MultiLineString:
+		return eachPointIn(g, fn)
+	case orb.Polygon:
+		return eachPointIn(g, fn)
+	case orb.MultiPolygon:
+		return eachPointIn(g, fn)
+	case orb.Collection:
+		return eachPointIn(g, fn)
+	case orb.Bound:
+		return eachPointIn([]orb.Point{g.Min, g.Max}, fn)
+	}
+
+	return true
+}
+
+func eachPointIn[G orb.Geometry](members []G, fn func(orb.Point) bool) bool {
+	for _, member := range members {
+		if !eachPoint(member, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isFinite reports whether every coordinate of geom is finite.
+func isFinite(geom orb.Geometry) bool {
+	return eachPoint(geom, func(p orb.Point) bool {
+		return !math.IsNaN(p[0]) && !math.IsInf(p[0], 0) && !math.IsNaN(p[1]) && !math.IsInf(p[1], 0)
+	})
+}
+
+// isEmpty reports whether geom holds no coordinates, such as an empty polygon
+// or a collection of empty members.
+func isEmpty(geom orb.Geometry) bool {
+	return eachPoint(geom, func(orb.Point) bool {
+		return false
+	})
+}
+
+// checkNilMembers reports an error naming the first nil member of the
+// collection geom or of a collection nested in it, which has no EWKB.
+func checkNilMembers(geom orb.Geometry) error {
+	c, ok := geom.(orb.Collection)
+	if !ok {
+		return nil
+	}
+
+	for i, member := range c {
+		if normalizeGeometry(member) == nil {
+			return fmt.Errorf("collection member %d is nil", i)
+		}
+
+		if err := checkNilMembers(member); err != nil {
+			return fmt.Errorf("collection member %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// dropNilMembers returns a copy of geom leaving out the nil members of the
+// collection geom and of the collections nested in it. Other geometries, and
+// a nil collection, which is sent as NULL, are returned as is.
+func dropNilMembers(geom orb.Geometry) orb.Geometry {
+	c, ok := geom.(orb.Collection)
+	if !ok || c == nil {
+		return geom
+	}
+
+	kept := make(orb.Collection, 0, len(c))
+	for _, member := range c {
+		if normalizeGeometry(member) != nil {
+			kept = append(kept, dropNilMembers(member))
+		}
+	}
+
+	return kept
+}
+
+// projectGeometry returns a copy of geom with fn applied to every coordinate.
+// geom itself is never modified.
+func projectGeometry(geom orb.Geometry, fn orb.Projection) (orb.Geometry, error) {
+	switch geom.(type) {
+	case orb.Point, orb.MultiPoint, orb.LineString, orb.MultiLineString, orb.Ring,
+		orb.Polygon, orb.MultiPolygon, orb.Collection, orb.Bound:
+	default:
+		// orb.Clone and project.Geometry panic on other types.
+		return nil, fmt.Errorf("unsupported geometry type %T", geom)
+	}
+
+	return project.Geometry(orb.Clone(geom), fn), nil
+}
+
+// snapToGrid returns a copy of geom with every coordinate snapped to the
+// nearest multiple of size. Negative zeros are snapped to zero, so the EWKB
+// of coordinates on either side of it is the same. geom itself is never
+// modified.
+func snapToGrid(geom orb.Geometry, size float64) (orb.Geometry, error) {
+	return projectGeometry(geom, func(p orb.Point) orb.Point {
+		return orb.Point{snap(p[0], size), snap(p[1], size)}
+	})
+}
+
+// snap returns the multiple of size nearest to v.
+func snap(v, size float64) float64 {
+	// Adding zero turns a negative zero positive.
+	return math.Round(v/size)*size + 0
+}
+
+// swapAxes returns a copy of geom with the X and Y of every coordinate
+// exchanged, e.g. turning lat/lon into lon/lat. geom itself is never
+// modified.
+func swapAxes(geom orb.Geometry) (orb.Geometry, error) {
+	return projectGeometry(geom, func(p orb.Point) orb.Point {
+		return orb.Point{p[1], p[0]}
+	})
+}
@@ -0,0 +1,306 @@
+package pgxorb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// TWKB geometry types, the low four bits of the first header byte.
+const (
+	twkbPoint = iota + 1
+	twkbLineString
+	twkbPolygon
+	twkbMultiPoint
+	twkbMultiLineString
+	twkbMultiPolygon
+	twkbCollection
+)
+
+// Flags of the TWKB metadata header byte.
+const (
+	twkbHasBBox     = 0x01
+	twkbHasSize     = 0x02
+	twkbHasIDList   = 0x04
+	twkbHasExtended = 0x08
+	twkbIsEmpty     = 0x10
+)
+
+// DecodeTWKB decodes a geometry in the Tiny WKB format produced by
+// ST_AsTWKB, as stored in bytea columns of space-constrained tables. Bounding
+// boxes and id lists are skipped, and Z and M coordinates are dropped. An
+// empty point decodes to a point of NaN coordinates, as with EWKB. An empty
+// src, such as a NULL scanned into a []byte, decodes to a nil geometry.
+func DecodeTWKB(src []byte) (orb.Geometry, error) {
+	if len(src) == 0 {
+		return nil, nil
+	}
+
+	r := bytes.NewReader(src)
+	geom, err := readTWKB(r, 1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid twkb: %w", err)
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after twkb geometry", r.Len())
+	}
+
+	return geom, nil
+}
+
+// twkbReader reads the coordinates of a single TWKB geometry, each of which
+// is stored as the difference to the previous one.
+type twkbReader struct {
+	r         *bytes.Reader
+	dims      int
+	precision int
+	last      [4]int64
+}
+
+// readTWKB reads a TWKB geometry at depth, header included, from r, the
+// geometry read first being at depth 1.
+func readTWKB(r *bytes.Reader, depth int) (orb.Geometry, error) {
+	if depth > maxNestingDepth {
+		return nil, fmt.Errorf("geometry nested deeper than %d levels", maxNestingDepth)
+	}
+
+	typeAndPrecision, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	metadata, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	// The precision is a zigzag encoded 4-bit number.
+	zigzag := int(typeAndPrecision >> 4)
+	t := twkbReader{r: r, dims: 2, precision: zigzag>>1 ^ -(zigzag & 1)}
+
+	if metadata&twkbHasExtended != 0 {
+		extended, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		t.dims += int(extended & 0x01)
+		t.dims += int(extended >> 1 & 0x01)
+	}
+
+	if metadata&twkbHasSize != 0 {
+		if _, err := t.uvarint(); err != nil {
+			return nil, err
+		}
+	}
+
+	if metadata&twkbHasBBox != 0 {
+		// A minimum and a delta per dimension.
+		for range 2 * t.dims {
+			if _, err := t.varint(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	geomType := typeAndPrecision & 0x0f
+	if metadata&twkbIsEmpty != 0 {
+		return emptyTWKB(geomType)
+	}
+
+	switch geomType {
+	case twkbPoint:
+		return t.point()
+	case twkbLineString:
+		return t.lineString()
+	case twkbPolygon:
+		return t.polygon()
+	}
+
+	n, err := t.count(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	switch geomType {
+	case twkbMultiPoint:
+		mp := make(orb.MultiPoint, n)
+		for i := range mp {
+			if mp[i], err = t.point(); err != nil {
+				return nil, err
+			}
+		}
+		return mp, nil
+	case twkbMultiLineString:
+		mls := make(orb.MultiLineString, n)
+		for i := range mls {
+			if mls[i], err = t.lineString(); err != nil {
+				return nil, err
+			}
+		}
+		return mls, nil
+	case twkbMultiPolygon:
+		mp := make(orb.MultiPolygon, n)
+		for i := range mp {
+			if mp[i], err = t.polygon(); err != nil {
+				return nil, err
+			}
+		}
+		return mp, nil
+	case twkbCollection:
+		c := make(orb.Collection, n)
+		for i := range c {
+			if c[i], err = readTWKB(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown geometry type %d", geomType)
+	}
+}
+
+// emptyTWKB returns the empty geometry of geomType.
+func emptyTWKB(geomType byte) (orb.Geometry, error) {
+	switch geomType {
+	case twkbPoint:
+		return orb.Point{math.NaN(), math.NaN()}, nil
+	case twkbLineString:
+		return orb.LineString{}, nil
+	case twkbPolygon:
+		return orb.Polygon{}, nil
+	case twkbMultiPoint:
+		return orb.MultiPoint{}, nil
+	case twkbMultiLineString:
+		return orb.MultiLineString{}, nil
+	case twkbMultiPolygon:
+		return orb.MultiPolygon{}, nil
+	case twkbCollection:
+		return orb.Collection{}, nil
+	default:
+		return nil, fmt.Errorf("unknown geometry type %d", geomType)
+	}
+}
+
+// count reads the number of members of a multi-geometry or collection and
+// skips the id list following it, if any.
+func (t *twkbReader) count(metadata byte) (int, error) {
+	n, err := t.length()
+	if err != nil {
+		return 0, err
+	}
+
+	if metadata&twkbHasIDList != 0 {
+		for range n {
+			if _, err := t.varint(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (t *twkbReader) point() (orb.Point, error) {
+	var p orb.Point
+	for i := range t.dims {
+		delta, err := t.varint()
+		if err != nil {
+			return orb.Point{}, err
+		}
+		t.last[i] += delta
+
+		if i < 2 {
+			p[i] = t.coord(t.last[i])
+		}
+	}
+
+	return p, nil
+}
+
+// coord scales the integer v back by the precision of the geometry, the
+// number of decimal digits kept. Dividing and multiplying by exact powers of
+// ten, rather than multiplying by an inexact 0.1, keeps e.g. 42287 at
+// precision 3 exactly 42.287.
+func (t *twkbReader) coord(v int64) float64 {
+	if t.precision < 0 {
+		return float64(v) * math.Pow10(-t.precision)
+	}
+
+	return float64(v) / math.Pow10(t.precision)
+}
+
+func (t *twkbReader) lineString() (orb.LineString, error) {
+	n, err := t.length()
+	if err != nil {
+		return nil, err
+	}
+
+	ls := make(orb.LineString, n)
+	for i := range ls {
+		if ls[i], err = t.point(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ls, nil
+}
+
+func (t *twkbReader) polygon() (orb.Polygon, error) {
+	n, err := t.length()
+	if err != nil {
+		return nil, err
+	}
+
+	p := make(orb.Polygon, n)
+	for i := range p {
+		ls, err := t.lineString()
+		if err != nil {
+			return nil, err
+		}
+		p[i] = orb.Ring(ls)
+	}
+
+	return p, nil
+}
+
+// length reads an element count, rejecting counts exceeding the bytes left,
+// as every element takes at least one.
+func (t *twkbReader) length() (int, error) {
+	n, err := t.uvarint()
+	if err != nil {
+		return 0, err
+	}
+
+	if n > uint64(t.r.Len()) {
+		return 0, fmt.Errorf("count %d exceeds the %d bytes left", n, t.r.Len())
+	}
+
+	return int(n), nil
+}
+
+func (t *twkbReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(t.r)
+	return v, unexpectedEOF(err)
+}
+
+// varint reads a zigzag encoded signed varint, as [binary.ReadVarint] does.
+func (t *twkbReader) varint() (int64, error) {
+	v, err := binary.ReadVarint(t.r)
+	return v, unexpectedEOF(err)
+}
+
+// unexpectedEOF turns io.EOF, as returned for missing bytes, into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,156 @@
+package pgxorb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// A geometryWrapper is a parameter type wrapping the geometry to encode.
+type geometryWrapper interface {
+	// unwrap returns the wrapped geometry and the SRID to encode it with,
+	// given the SRID configured for the codec.
+	unwrap(srid int) (orb.Geometry, int, error)
+}
+
+// A Flattener is a geometry with Z or M ordinates, such as a wrapper of 3D
+// coordinates, that can project itself onto an orb geometry. Flatteners are
+// only encoded with [WithForce2D], so dimensions are never dropped silently.
+type Flattener interface {
+	// Flatten returns the geometry without its Z and M ordinates.
+	Flatten() orb.Geometry
+}
+
+// An EWKBMarshaler is a parameter type outside orb's model, such as a 3D
+// point, that encodes itself as PostGIS EWKB. Its bytes are sent as is,
+// bypassing the options that transform orb geometries.
+type EWKBMarshaler interface {
+	// MarshalEWKB returns the EWKB of the value, given the SRID and byte order
+	// configured for the codec, or nil to send NULL.
+	MarshalEWKB(srid int, order binary.ByteOrder) ([]byte, error)
+}
+
+// A GeometryWithSRID is a geometry together with its SRID. Scanned into, it
+// keeps the SRID of the stored geometry, which is 0 when none is set, and the
+// geometry is not converted by [WithGeometryFactory]. As a parameter it is
+// encoded with its own SRID instead of the configured one.
+type GeometryWithSRID struct {
+	Geometry orb.Geometry
+	SRID     int
+}
+
+func (g GeometryWithSRID) unwrap(int) (orb.Geometry, int, error) {
+	return g.Geometry, g.SRID, nil
+}
+
+// A TransformedGeometry is a geometry parameter reprojected on the client
+// before it is encoded, instead of with ST_Transform on the server.
+type TransformedGeometry struct {
+	Geometry orb.Geometry
+	// Projection maps every coordinate of Geometry, e.g. from SRID 4326 to
+	// 3857 with [github.com/paulmach/orb/project.WGS84.ToMercator]. Encoding
+	// fails without one.
+	Projection orb.Projection
+	// SRID is the SRID of the projected coordinates. When 0, the configured
+	// SRID is used.
+	SRID int
+}
+
+// Transform wraps geom as a parameter encoded with fn applied to all of its
+// coordinates. geom itself is not modified.
+func Transform(geom orb.Geometry, fn orb.Projection) TransformedGeometry {
+	return TransformedGeometry{Geometry: geom, Projection: fn}
+}
+
+func (t TransformedGeometry) unwrap(srid int) (orb.Geometry, int, error) {
+	if t.Projection == nil {
+		return nil, 0, errors.New("TransformedGeometry has no Projection")
+	}
+
+	if t.SRID != 0 {
+		srid = t.SRID
+	}
+
+	if t.Geometry == nil {
+		return nil, srid, nil
+	}
+
+	geom, err := projectGeometry(t.Geometry, t.Projection)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return geom, srid, nil
+}
+
+// A TypedGeometry is a geometry parameter for a typmod constrained column
+// such as geometry(Point, 4326). It is always encoded with SRID, and a
+// geometry that isn't of Type is rejected before anything is sent, instead
+// of failing with a constraint error on the server.
+type TypedGeometry struct {
+	Geometry orb.Geometry
+	// Type is the PostGIS geometry type of the column, e.g. "Point" or
+	// "MultiPolygon", compared case-insensitively. "Geometry" accepts any
+	// geometry type.
+	Type string
+	SRID int
+}
+
+// Typed wraps geom as a parameter for a column of the given PostGIS geometry
+// type and SRID.
+func Typed(geom orb.Geometry, geomType string, srid int) TypedGeometry {
+	return TypedGeometry{Geometry: geom, Type: geomType, SRID: srid}
+}
+
+func (t TypedGeometry) unwrap(int) (orb.Geometry, int, error) {
+	if t.Geometry == nil || strings.EqualFold(t.Type, "Geometry") {
+		return t.Geometry, t.SRID, nil
+	}
+
+	// orb names its types after GeoJSON, which match the PostGIS names.
+	if actual := t.Geometry.GeoJSONType(); !strings.EqualFold(actual, t.Type) {
+		return nil, 0, fmt.Errorf("geometry type %s doesn't match column type %s", actual, t.Type)
+	}
+
+	return t.Geometry, t.SRID, nil
+}
+
+// A TextGeometry is a geometry parameter sent in text format, as hex EWKB,
+// which is easier to read than binary in server logs. pgx picks the format of
+// a parameter by the type of its column rather than by its value, so the codec
+// declines to send a TextGeometry in binary and pgx falls back to text. COPY
+// only sends binary, so copy the plain geometry instead.
+type TextGeometry struct {
+	Geometry orb.Geometry
+}
+
+// TextBelow wraps geom in a [TextGeometry] when its EWKB takes at most size
+// bytes, such as the 25 bytes of a point, so small geometries are sent in
+// text and larger ones, returned as is, in binary. The size is computed from
+// the structure of geom without encoding it.
+func TextBelow(geom orb.Geometry, size int) any {
+	n, err := ewkbSize(normalizeGeometry(geom), true)
+	if err != nil || n > size {
+		return geom
+	}
+
+	return TextGeometry{Geometry: geom}
+}
+
+func (t TextGeometry) unwrap(srid int) (orb.Geometry, int, error) {
+	return t.Geometry, srid, nil
+}
+
+// isTextGeometry reports whether value is a [TextGeometry] or a pointer to
+// one.
+func isTextGeometry(value any) bool {
+	switch value.(type) {
+	case TextGeometry, *TextGeometry:
+		return true
+	default:
+		return false
+	}
+}
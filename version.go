@@ -0,0 +1,57 @@
+package pgxorb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// checkPostGISVersion queries the version of the PostGIS library serving
+// conn and reports [ErrUnsupportedPostGIS] if it is older than minVersion. An
+// empty minVersion accepts any version.
+func checkPostGISVersion(ctx context.Context, conn *pgx.Conn, minVersion string) (string, error) {
+	var version string
+	if err := conn.QueryRow(ctx, "select postgis_lib_version()").Scan(&version); err != nil {
+		return "", fmt.Errorf("get postgis version failed on %s: %w", describeConn(conn), err)
+	}
+
+	if minVersion != "" && compareVersions(version, minVersion) < 0 {
+		return "", fmt.Errorf("%w: version %s is older than %s on %s",
+			ErrUnsupportedPostGIS, version, minVersion, describeConn(conn))
+	}
+
+	return version, nil
+}
+
+// compareVersions compares the dotted versions a and b, such as 3.4.2,
+// component by component, returning -1, 0 or +1. Missing components count
+// as 0 and suffixes such as the dev in 3.5.0dev are ignored.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		if c := versionComponent(as, i) - versionComponent(bs, i); c != 0 {
+			if c < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// versionComponent returns the number leading the i-th component of a
+// version, or 0 if there is none.
+func versionComponent(components []string, i int) int {
+	if i >= len(components) {
+		return 0
+	}
+
+	digits := strings.TrimLeft(components[i], "0123456789")
+	n, _ := strconv.Atoi(components[i][:len(components[i])-len(digits)])
+
+	return n
+}
@@ -0,0 +1,335 @@
+package pgxorb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// maxNestingDepth limits how deeply the geometries walked or decoded by
+// this package may nest, so hostile input can't exhaust the stack. Real
+// geometries rarely nest more than a few levels.
+const maxNestingDepth = 256
+
+// An ewkbWalker walks the structure of an EWKB geometry read from r without
+// decoding its coordinates, optionally collecting the bytes it consumes.
+type ewkbWalker struct {
+	r io.Reader
+	// raw receives the consumed bytes when non-nil.
+	raw *bytes.Buffer
+	// coord, when non-nil, receives every coordinate in order instead of it
+	// being skipped. Z and M ordinates are dropped.
+	coord func(orb.Point) error
+	// stripBBoxes leaves the embedded bounding boxes out of raw and clears
+	// their flags.
+	stripBBoxes bool
+	// maxDepth, when positive, limits how deeply geometries may nest, the
+	// geometry walked first being at depth 1. It defaults to
+	// maxNestingDepth.
+	maxDepth int
+	depth    int
+	// vertices counts the coordinates walked.
+	vertices int64
+	// bboxes counts the headers with an embedded bounding box.
+	bboxes   int
+	consumed int64
+	scratch  [8]byte
+}
+
+// checkDepth reports an error if the geometries of the EWKB in src nest
+// deeper than maxDepth, before decoding, which recurses into every level,
+// exhausts the stack on hostile input.
+func checkDepth(src []byte, maxDepth int) error {
+	w := ewkbWalker{r: bytes.NewReader(src), maxDepth: maxDepth}
+
+	return w.geometry()
+}
+
+// stripBBoxes returns src with the embedded bounding boxes of the geometry
+// and of all of its members removed and their flags cleared, as orb's decoder
+// doesn't support them, or src itself when it holds none. Bytes following the
+// geometry are kept.
+func stripBBoxes(src []byte) ([]byte, error) {
+	w := ewkbWalker{r: bytes.NewReader(src)}
+	if err := w.geometry(); err != nil {
+		return nil, invalidEWKB(err)
+	}
+
+	if w.bboxes == 0 {
+		return src, nil
+	}
+
+	raw := bytes.NewBuffer(make([]byte, 0, len(src)))
+	w = ewkbWalker{r: bytes.NewReader(src), raw: raw, stripBBoxes: true}
+	if err := w.geometry(); err != nil {
+		return nil, invalidEWKB(err)
+	}
+
+	return append(raw.Bytes(), src[w.consumed:]...), nil
+}
+
+// invalidEWKB classifies an error of a walk over malformed input as
+// [ErrInvalidEWKB], keeping the errors already classified.
+func invalidEWKB(err error) error {
+	var classified *classError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrInvalidEWKB, err)
+}
+
+// geometry walks a complete geometry, including its header.
+func (w *ewkbWalker) geometry() error {
+	maxDepth := w.maxDepth
+	if maxDepth <= 0 {
+		maxDepth = maxNestingDepth
+	}
+
+	w.depth++
+	defer func() { w.depth-- }()
+	if w.depth > maxDepth {
+		return classErrorf(ErrInvalidEWKB, "geometry nested deeper than %d levels", maxDepth)
+	}
+
+	h, err := w.header()
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkSupported(); err != nil {
+		return err
+	}
+
+	return w.body(h)
+}
+
+func (w *ewkbWalker) header() (ewkbHeader, error) {
+	b, err := w.read(5)
+	if err != nil {
+		return ewkbHeader{}, err
+	}
+
+	h, err := parseTypeWord(b)
+	if err != nil {
+		return ewkbHeader{}, err
+	}
+
+	if h.hasSRID {
+		b, err := w.read(4)
+		if err != nil {
+			return ewkbHeader{}, err
+		}
+		h.setSRID(b)
+	}
+
+	if h.hasBBox {
+		w.bboxes++
+		if err := w.skipBBox(h); err != nil {
+			return ewkbHeader{}, err
+		}
+	}
+
+	return h, nil
+}
+
+// skipBBox skips the bounding box following the header h, which was the last
+// thing read, leaving it and its flag out of raw with stripBBoxes.
+func (w *ewkbWalker) skipBBox(h ewkbHeader) error {
+	if !w.stripBBoxes || w.raw == nil {
+		return w.skip(int64(h.bboxSize()))
+	}
+
+	written := w.raw.Bytes()
+	typeWord := written[len(written)-h.size+1:]
+	h.order.PutUint32(typeWord, h.order.Uint32(typeWord)&^ewkbBBoxFlag)
+
+	raw := w.raw
+	w.raw = nil
+	defer func() { w.raw = raw }()
+
+	return w.skip(int64(h.bboxSize()))
+}
+
+func (w *ewkbWalker) body(h ewkbHeader) error {
+	switch h.typ {
+	case pointType:
+		return w.point1(h)
+	case lineStringType:
+		return w.points(h)
+	case polygonType:
+		return w.rings(h)
+	case multiPointType, multiLineStringType, multiPolygonType, geometryCollectionType:
+		return w.members(h)
+	default:
+		return fmt.Errorf("unsupported geometry type %d", h.typ)
+	}
+}
+
+// point1 walks the coordinate of a point, which isn't counted as a vertex
+// when its X and Y are NaN, as PostGIS encodes POINT EMPTY.
+func (w *ewkbWalker) point1(h ewkbHeader) error {
+	p, err := w.point(h)
+	if err != nil {
+		return err
+	}
+
+	if !math.IsNaN(p[0]) || !math.IsNaN(p[1]) {
+		w.vertices++
+	}
+
+	if w.coord == nil {
+		return nil
+	}
+
+	return w.coord(p)
+}
+
+// points walks a counted sequence of coordinates.
+func (w *ewkbWalker) points(h ewkbHeader) error {
+	n, err := w.count(h)
+	if err != nil {
+		return err
+	}
+
+	return w.coordinates(h, int64(n))
+}
+
+// rings walks the counted rings of a polygon.
+func (w *ewkbWalker) rings(h ewkbHeader) error {
+	n, err := w.count(h)
+	if err != nil {
+		return err
+	}
+
+	for range n {
+		if err := w.points(h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// coordinates walks n coordinates, passing each to w.coord when set and
+// skipping them otherwise.
+func (w *ewkbWalker) coordinates(h ewkbHeader, n int64) error {
+	w.vertices += n
+	if w.coord == nil {
+		return w.skip(n * int64(8*h.dims()))
+	}
+
+	for range n {
+		p, err := w.point(h)
+		if err != nil {
+			return err
+		}
+
+		if err := w.coord(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// point reads a single coordinate, dropping any Z and M ordinates.
+func (w *ewkbWalker) point(h ewkbHeader) (orb.Point, error) {
+	var p orb.Point
+	for i := range p {
+		b, err := w.read(8)
+		if err != nil {
+			return orb.Point{}, err
+		}
+		p[i] = math.Float64frombits(h.order.Uint64(b))
+	}
+
+	return p, w.skip(int64(8 * (h.dims() - 2)))
+}
+
+// members walks the counted sub-geometries of a multi-geometry or
+// collection, each of which carries its own header and byte order.
+func (w *ewkbWalker) members(h ewkbHeader) error {
+	n, err := w.count(h)
+	if err != nil {
+		return err
+	}
+
+	for range n {
+		if err := w.geometry(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *ewkbWalker) count(h ewkbHeader) (uint32, error) {
+	b, err := w.read(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return h.order.Uint32(b), nil
+}
+
+// read reads the next n bytes, at most len(w.scratch). The returned slice is
+// only valid until the next read.
+func (w *ewkbWalker) read(n int) ([]byte, error) {
+	b := w.scratch[:n]
+	if _, err := io.ReadFull(w.r, b); err != nil {
+		return nil, w.eof(err)
+	}
+
+	w.consumed += int64(n)
+	if w.raw != nil {
+		w.raw.Write(b)
+	}
+
+	return b, nil
+}
+
+// skip consumes the next n bytes. They are streamed rather than allocated up
+// front, so a corrupt count fails at the end of input instead of exhausting
+// memory.
+func (w *ewkbWalker) skip(n int64) error {
+	// Without bytes to collect, input held in memory is skipped over.
+	if r, ok := w.r.(*bytes.Reader); ok && w.raw == nil {
+		if left := int64(r.Len()); left < n {
+			n = left
+			_, _ = r.Seek(n, io.SeekCurrent)
+			w.consumed += n
+			return w.eof(io.EOF)
+		}
+		_, _ = r.Seek(n, io.SeekCurrent)
+		w.consumed += n
+		return nil
+	}
+
+	dst := io.Discard
+	if w.raw != nil {
+		dst = w.raw
+	}
+
+	copied, err := io.CopyN(dst, w.r, n)
+	w.consumed += copied
+	if err != nil {
+		return w.eof(err)
+	}
+
+	return nil
+}
+
+// eof reports running out of input in the middle of a geometry as
+// io.ErrUnexpectedEOF, and before its first byte as io.EOF.
+func (w *ewkbWalker) eof(err error) error {
+	if w.consumed > 0 && errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package pgxorb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkt"
+)
+
+// ParseWKT parses the WKT text of a geometry, such as the output of
+// ST_AsText, for queries returning geometries as plain text columns. The
+// SRID=n; prefix of ST_AsEWKT output is accepted and dropped.
+func ParseWKT(s string) (orb.Geometry, error) {
+	if rest, ok := strings.CutPrefix(s, "SRID="); ok {
+		_, s, ok = strings.Cut(rest, ";")
+		if !ok {
+			return nil, fmt.Errorf("invalid ewkt srid prefix in %q", s)
+		}
+	}
+
+	var (
+		geom orb.Geometry
+		err  error
+	)
+	if body, ok := cutTag(s, "MULTIPOINT"); ok {
+		geom, err = parseMultiPoint(body)
+	} else {
+		geom, err = wkt.Unmarshal(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wkt geometry: %w", err)
+	}
+
+	return geom, nil
+}
+
+// ToWKT returns the WKT text of geom, such as POINT(1 2), for logging. A nil
+// geom, as scanned from a NULL, is written as NULL, and geometry types orb
+// can't write as a description of the type.
+func ToWKT(geom orb.Geometry) string {
+	switch geom.(type) {
+	case nil:
+		return "NULL"
+	case orb.Point, orb.MultiPoint, orb.LineString, orb.MultiLineString, orb.Ring,
+		orb.Polygon, orb.MultiPolygon, orb.Collection, orb.Bound:
+		return wkt.MarshalString(geom)
+	default:
+		// wkt.MarshalString panics on other types.
+		return fmt.Sprintf("unsupported geometry type %T", geom)
+	}
+}
+
+// cutTag returns s without its leading geometry tag, matched
+// case-insensitively, and reports whether s starts with it.
+func cutTag(s, tag string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < len(tag) || !strings.EqualFold(s[:len(tag)], tag) {
+		return "", false
+	}
+
+	return strings.TrimSpace(s[len(tag):]), true
+}
+
+// parseMultiPoint parses the body of a MULTIPOINT, whose points PostGIS writes
+// without parentheses, as in MULTIPOINT(1 2,3 4), while orb only accepts them
+// parenthesized.
+func parseMultiPoint(body string) (orb.Geometry, error) {
+	if strings.EqualFold(body, "EMPTY") {
+		return orb.MultiPoint{}, nil
+	}
+
+	if !strings.HasPrefix(body, "(") || !strings.HasSuffix(body, ")") {
+		return nil, wkt.ErrNotWKT
+	}
+	inner := body[1 : len(body)-1]
+
+	var mp orb.MultiPoint
+	for _, point := range strings.Split(inner, ",") {
+		point = strings.Trim(strings.TrimSpace(point), "()")
+		p, err := wkt.UnmarshalPoint("POINT(" + point + ")")
+		if err != nil {
+			return nil, err
+		}
+		mp = append(mp, p)
+	}
+
+	return mp, nil
+}